@@ -6,12 +6,16 @@ import (
 
 	"github.com/hashicorp/consul/api"
 	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/acl"
+	"github.com/myENA/consul-backinator/common/configentry"
+	"github.com/myENA/consul-backinator/common/intention"
 )
 
 // backupKeys fetches key/value pairs from consul and writes them to a backup file
 func (c *Command) backupKeys() (int, error) {
 	var kvps api.KVPairs       // list of requested kv pairs
 	var opts *api.QueryOptions // client query options
+	var meta *api.QueryMeta    // client query metadata
 	var count int              // key count
 	var data []byte            // read keys
 	var err error              // general error holder
@@ -23,18 +27,28 @@ func (c *Command) backupKeys() (int, error) {
 	}
 
 	// get all keys
-	if kvps, _, err = c.consulClient.KV().List(c.config.consulPrefix, opts); err != nil {
+	if kvps, meta, err = c.consulClient.KV().List(c.config.consulPrefix, opts); err != nil {
 		return 0, err
 	}
 
+	// record the index the keys were read at
+	if c.bundle != nil {
+		c.bundle.Manifest.Index = meta.LastIndex
+	}
+
 	// transform paths
 	c.pathTransformer.Transform(kvps)
 
 	// set count
 	count = len(kvps)
 
+	// only store changes if requested - an empty tree is a valid change
+	if c.config.incremental != "" {
+		return c.backupIncrement(kvps)
+	}
+
 	// check count
-	if count == 0 {
+	if count == 0 && !c.AllowEmpty {
 		return 0, errors.New("No keys found")
 	}
 
@@ -44,7 +58,7 @@ func (c *Command) backupKeys() (int, error) {
 	}
 
 	// write data to destination
-	if err = common.WriteData(c.config.fileName, c.config.cryptKey, data); err != nil {
+	if err = c.writeSection(common.SectionKV, c.config.fileName, count, data); err != nil {
 		return 0, err
 	}
 
@@ -52,12 +66,13 @@ func (c *Command) backupKeys() (int, error) {
 	return count, nil
 }
 
-// backupACLs fetches acl tokens consul and writes them to a backup file
+// backupACLs fetches acl policies, roles, auth methods, binding rules
+// and tokens from consul and writes them to a backup file
 func (c *Command) backupACLs() (int, error) {
-	var acls []*api.ACLEntry   // list of acl tokens
+	var snap *acl.Snapshot     // acl snapshot
 	var opts *api.QueryOptions // client query options
-	var count int              // token count
-	var data []byte            // read tokens
+	var count int              // object count
+	var data []byte            // encoded snapshot
 	var err error              // general error holder
 
 	// build query options
@@ -66,30 +81,30 @@ func (c *Command) backupACLs() (int, error) {
 		RequireConsistent: true,
 	}
 
-	// get all acl tokens
-	if acls, _, err = c.consulClient.ACL().List(opts); err != nil {
+	// get all acl objects
+	if snap, err = acl.Fetch(c.consulClient, opts); err != nil {
 		return 0, err
 	}
 
 	// set count
-	count = len(acls)
+	count = snap.Count()
 
 	// check count
-	if count == 0 {
+	if len(snap.Tokens) == 0 && !c.AllowEmpty {
 		return 0, errors.New("No tokens found")
 	}
 
 	// encode and return
-	if data, err = json.MarshalIndent(acls, "", "  "); err != nil {
+	if data, err = json.MarshalIndent(snap, "", "  "); err != nil {
 		return 0, err
 	}
 
 	// write data to destination
-	if err = common.WriteData(c.config.aclFileName, c.config.cryptKey, data); err != nil {
+	if err = c.writeSection(common.SectionACLs, c.config.aclFileName, count, data); err != nil {
 		return 0, err
 	}
 
-	// return token count - no error
+	// return object count - no error
 	return count, nil
 }
 
@@ -116,7 +131,7 @@ func (c *Command) backupQueries() (int, error) {
 	count = len(queries)
 
 	// check count
-	if count == 0 {
+	if count == 0 && !c.AllowEmpty {
 		return 0, errors.New("No query definitions found")
 	}
 
@@ -126,10 +141,92 @@ func (c *Command) backupQueries() (int, error) {
 	}
 
 	// write data to destination
-	if err = common.WriteData(c.config.queryFileName, c.config.cryptKey, data); err != nil {
+	if err = c.writeSection(common.SectionQueries, c.config.queryFileName, count, data); err != nil {
 		return 0, err
 	}
 
 	// return query count - no error
 	return count, nil
 }
+
+// backupConfigEntries fetches config entries from consul and writes them to a backup file
+func (c *Command) backupConfigEntries() (int, error) {
+	var entries []api.ConfigEntry // list of config entries
+	var opts *api.QueryOptions    // client query options
+	var count int                 // entry count
+	var data []byte               // read entries
+	var err error                 // general error holder
+
+	// build query options
+	opts = &api.QueryOptions{
+		AllowStale:        false,
+		RequireConsistent: true,
+	}
+
+	// get all config entries
+	if entries, err = configentry.Fetch(c.consulClient, opts); err != nil {
+		return 0, err
+	}
+
+	// set count
+	count = len(entries)
+
+	// check count
+	if count == 0 && !c.AllowEmpty {
+		return 0, errors.New("No config entries found")
+	}
+
+	// encode and return
+	if data, err = json.MarshalIndent(entries, "", "  "); err != nil {
+		return 0, err
+	}
+
+	// write data to destination
+	if err = c.writeSection(common.SectionConfigs, c.config.configFileName, count, data); err != nil {
+		return 0, err
+	}
+
+	// return entry count - no error
+	return count, nil
+}
+
+// backupIntentions fetches intentions from consul and writes them to a backup file
+func (c *Command) backupIntentions() (int, error) {
+	var snap *intention.Snapshot // intention snapshot
+	var opts *api.QueryOptions   // client query options
+	var count int                // intention count
+	var data []byte              // encoded snapshot
+	var err error                // general error holder
+
+	// build query options
+	opts = &api.QueryOptions{
+		AllowStale:        false,
+		RequireConsistent: true,
+	}
+
+	// get all intentions
+	if snap, err = intention.Fetch(c.consulClient, opts); err != nil {
+		return 0, err
+	}
+
+	// set count
+	count = len(snap.Intentions)
+
+	// check count
+	if count == 0 && !c.AllowEmpty {
+		return 0, errors.New("No intentions found")
+	}
+
+	// encode and return
+	if data, err = json.MarshalIndent(snap, "", "  "); err != nil {
+		return 0, err
+	}
+
+	// write data to destination
+	if err = c.writeSection(common.SectionIntentions, c.config.intentionFileName, count, data); err != nil {
+		return 0, err
+	}
+
+	// return intention count - no error
+	return count, nil
+}
@@ -4,29 +4,43 @@ import (
 	"fmt"
 	stdLog "log"
 
+	"github.com/myENA/consul-backinator/common"
 	ccns "github.com/myENA/consul-backinator/common/consul"
 	ct "github.com/myENA/consul-backinator/common/transformer"
 )
 
 // primary configuration
 type config struct {
-	fileName      string
-	cryptKey      string
-	noKV          bool
-	aclFileName   string
-	queryFileName string
-	pathTransform string
-	consulPrefix  string
-	consulConfig  *ccns.Config
+	fileName          string
+	sigName           string
+	cryptKey          string
+	recipients        string
+	signKey           string
+	keys              *common.Keys
+	noKV              bool
+	aclFileName       string
+	queryFileName     string
+	configFileName    string
+	intentionFileName string
+	bundle            string
+	pathTransform     string
+	incremental       string
+	identity          string
+	consulPrefix      string
+	consulConfig      *ccns.Config
 }
 
-// Command is a Command implementation that runs the backup operation
+// Command is a Command implementation that runs the backup operation.
+// Empty sections are treated as errors unless AllowEmpty is set.
 type Command struct {
 	Self            string
+	Version         string
+	AllowEmpty      bool
 	Log             *stdLog.Logger
 	config          *config
 	consulClient    *ccns.Client
 	pathTransformer *ct.PathTransformer
+	bundle          *common.Bundle
 }
 
 // Run is a function to run the command
@@ -41,10 +55,11 @@ func (c *Command) Run(args []string) int {
 	}
 
 	// sanity check
-	if c.config.noKV && c.config.aclFileName == "" && c.config.queryFileName == "" {
-		c.Log.Printf("[Error] Passing 'nokv' without an 'acls' or 'queries' file " +
-			"doesn't make any sense.  You should specify an 'acls' or 'queries' file " +
-			"when using the 'nokv' option.")
+	if c.config.noKV && c.config.aclFileName == "" && c.config.queryFileName == "" &&
+		c.config.configFileName == "" && c.config.intentionFileName == "" {
+		c.Log.Printf("[Error] Passing 'nokv' without an 'acls', 'queries', 'configs' or " +
+			"'intentions' file doesn't make any sense.  You should specify an 'acls', " +
+			"'queries', 'configs' or 'intentions' file when using the 'nokv' option.")
 		return 1
 	}
 
@@ -60,6 +75,11 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	// init bundle if requested
+	if c.config.bundle != "" {
+		c.bundle = common.NewBundle(c.buildManifest())
+	}
+
 	// backup keys unless otherwise requested
 	if !c.config.noKV {
 		if count, err = c.backupKeys(); err != nil {
@@ -78,12 +98,12 @@ func (c *Command) Run(args []string) int {
 	// backup acls if requested
 	if c.config.aclFileName != "" {
 		if count, err = c.backupACLs(); err != nil {
-			c.Log.Printf("[Error] Failed to backup ACL tokens: %s", err.Error())
+			c.Log.Printf("[Error] Failed to backup ACL data: %s", err.Error())
 			return 1
 		}
 
 		// show success
-		c.Log.Printf("[Success] Backed up %d ACL tokens from %s to %s",
+		c.Log.Printf("[Success] Backed up %d ACL objects from %s to %s",
 			count,
 			c.config.consulConfig.Address,
 			c.config.aclFileName)
@@ -103,8 +123,54 @@ func (c *Command) Run(args []string) int {
 			c.config.queryFileName)
 	}
 
-	// make sure they know to keep the sig
-	fmt.Print("Keep your backup and signature files " +
+	// backup config entries if requested
+	if c.config.configFileName != "" {
+		if count, err = c.backupConfigEntries(); err != nil {
+			c.Log.Printf("[Error] Failed to backup config entries: %s", err.Error())
+			return 1
+		}
+
+		// show success
+		c.Log.Printf("[Success] Backed up %d config entries from %s to %s",
+			count,
+			c.config.consulConfig.Address,
+			c.config.configFileName)
+	}
+
+	// backup intentions if requested
+	if c.config.intentionFileName != "" {
+		if count, err = c.backupIntentions(); err != nil {
+			c.Log.Printf("[Error] Failed to backup intentions: %s", err.Error())
+			return 1
+		}
+
+		// show success
+		c.Log.Printf("[Success] Backed up %d intentions from %s to %s",
+			count,
+			c.config.consulConfig.Address,
+			c.config.intentionFileName)
+	}
+
+	// write bundle if requested
+	if c.bundle != nil {
+		if err = common.WriteBundle(c.config.fileName, c.config.sigName, c.config.keys, c.bundle); err != nil {
+			c.Log.Printf("[Error] Failed to write bundle: %s", err.Error())
+			return 1
+		}
+
+		// show success
+		c.Log.Printf("[Success] Wrote bundle with %d sections to %s",
+			len(c.bundle.Manifest.Sections),
+			c.config.fileName)
+	}
+
+	// show signer
+	if fp := c.config.keys.SignerFingerprint(); fp != "" {
+		c.Log.Printf("[Success] Signed backup data with key %s", fp)
+	}
+
+	// make sure they know to keep the sig without mixing it into a stream
+	fmt.Fprint(c.messages(), "Keep your backup and signature files "+
 		"in a safe place.\nYou will need both to restore your data.\n")
 
 	// exit clean
@@ -124,12 +190,20 @@ func (c *Command) Help() string {
 
 Options:
 
-	-file            Destination filename or S3 location (default: "consul.bak")
+	-file            Destination filename, S3 location or "-" to write to stdout (default: "consul.bak")
+	-sig             Optional signature location used instead of next to the destination or embedded in the stdout stream
 	-key             Passphrase for data encryption and signature validation (default: "password")
+	-recipients      Optional list of age public keys or files containing public keys to encrypt data to instead of the passphrase
+	-sign-key        Optional PEM encoded ed25519 private key file used to sign data instead of the passphrase
 	-nokv            Do not attempt to backup kv data
-	-acls            Optional backup filename or S3 location for acl tokens
+	-acls            Optional backup filename or S3 location for acl policies, roles and tokens
 	-queries         Optional backup filename or S3 location for prepared queries
+	-configs         Optional backup filename or S3 location for config entries
+	-intentions      Optional backup filename or S3 location for intentions
+	-bundle          Optional list of sections to write to a single bundle file (kv,acls,queries,configs,intentions or all)
 	-transform       Optional path transformation (oldPath,newPath...)
+	-incremental     Optional location of a previous bundle to store only keys changed since it was written
+	-identity        Optional age identity file used to read the previous bundle with incremental
 	-prefix          Optional prefix from under which all keys will be fetched
 	-addr            Optional consul address and port (default: "127.0.0.1:8500")
 	-scheme          Optional consul scheme ("http" or "https")
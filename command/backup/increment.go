@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/kv"
+)
+
+// ErrNoIndex is returned when the previous bundle does not record an index
+var ErrNoIndex = errors.New("Previous bundle does not record the index it was taken at")
+
+// backupIncrement writes the keys changed since the previous bundle
+// along with tombstones for keys deleted since the previous bundle
+func (c *Command) backupIncrement(kvps api.KVPairs) (int, error) {
+	var previous []string // keys present in the previous bundle
+	var since uint64      // index of the previous bundle
+	var inc *kv.Increment // changed keys
+	var data []byte       // encoded increment
+	var err error         // general error holder
+
+	// read previous bundle
+	if previous, since, err = c.readPrevious(); err != nil {
+		return 0, fmt.Errorf("Failed to read previous bundle %s: %s",
+			c.config.incremental, err.Error())
+	}
+
+	// build increment
+	inc = kv.NewIncrement(kvps, previous, since)
+
+	// record the chain in the manifest
+	c.bundle.Manifest.Since = since
+	c.bundle.Manifest.Parent = c.config.incremental
+
+	// show details
+	c.Log.Printf("[Info] Found %d changed and %d deleted keys since index %d",
+		len(inc.Pairs), len(inc.Deleted), since)
+
+	// encode increment
+	if data, err = json.MarshalIndent(inc, "", "  "); err != nil {
+		return 0, err
+	}
+
+	// write data to destination
+	if err = c.writeSection(common.SectionKV, c.config.fileName, inc.Count(), data); err != nil {
+		return 0, err
+	}
+
+	// return change count - no error
+	return inc.Count(), nil
+}
+
+// readPrevious returns the keys present when the previous bundle was
+// written and the index it was taken at
+func (c *Command) readPrevious() ([]string, uint64, error) {
+	var bundle *common.Bundle // previous bundle
+	var inc *kv.Increment     // previous increment
+	var kvps api.KVPairs      // previous pairs
+	var data []byte           // kv section data
+	var err error             // general error holder
+
+	// read bundle
+	if bundle, err = common.ReadBundle(c.config.incremental, "", c.config.keys); err != nil {
+		return nil, 0, err
+	}
+
+	// check index and prefix
+	if bundle.Manifest.Index == 0 {
+		return nil, 0, ErrNoIndex
+	}
+	if bundle.Manifest.Prefix != c.config.consulPrefix {
+		return nil, 0, fmt.Errorf("Previous bundle was taken with prefix '%s'",
+			bundle.Manifest.Prefix)
+	}
+
+	// read keys
+	if data, err = bundle.Section(common.SectionKV); err != nil {
+		return nil, 0, err
+	}
+
+	// previous increments list all keys present at the time
+	if kv.IsIncrement(data) {
+		if inc, err = kv.DecodeIncrement(data); err != nil {
+			return nil, 0, err
+		}
+		return inc.Keys, bundle.Manifest.Index, nil
+	}
+
+	// otherwise decode full backup
+	if kvps, err = kv.Decode(data); err != nil {
+		return nil, 0, err
+	}
+
+	// return keys and index
+	return kv.Keys(kvps), bundle.Manifest.Index, nil
+}
@@ -1,15 +1,29 @@
 package backup
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/myENA/consul-backinator/common"
 	cc "github.com/myENA/consul-backinator/common/config"
 	ccns "github.com/myENA/consul-backinator/common/consul"
 )
 
+// Exported option errors
+var (
+	ErrBundleFiles = errors.New("Separate 'acls', 'queries', 'configs' or 'intentions' " +
+		"files can not be combined with the 'bundle' option")
+	ErrIncrementalKV = errors.New("The 'incremental' option requires the 'kv' bundle section")
+)
+
+// defaultCryptKey is the passphrase used when no key is passed
+const defaultCryptKey = "password"
+
 // setupFlags initializes the instance configuration
 func (c *Command) setupFlags(args []string) error {
 	var cmdFlags *flag.FlagSet // instance flagset
@@ -32,16 +46,32 @@ func (c *Command) setupFlags(args []string) error {
 	// declare flags
 	cmdFlags.StringVar(&c.config.fileName, "file", "consul.bak",
 		"Destination")
-	cmdFlags.StringVar(&c.config.cryptKey, "key", "password",
+	cmdFlags.StringVar(&c.config.sigName, "sig", "",
+		"Optional signature location")
+	cmdFlags.StringVar(&c.config.cryptKey, "key", defaultCryptKey,
 		"Passphrase for data encryption and signature validation")
+	cmdFlags.StringVar(&c.config.recipients, "recipients", "",
+		"Optional list of public keys to encrypt data to")
+	cmdFlags.StringVar(&c.config.signKey, "sign-key", "",
+		"Optional private key file used to sign data")
 	cmdFlags.BoolVar(&c.config.noKV, "nokv", false,
 		"Do not attempt to backup kv data")
 	cmdFlags.StringVar(&c.config.aclFileName, "acls", "",
-		"Optional backup filename for acl tokens")
+		"Optional backup filename for acl data")
 	cmdFlags.StringVar(&c.config.queryFileName, "queries", "",
 		"Optional backup filename for query definitions")
+	cmdFlags.StringVar(&c.config.configFileName, "configs", "",
+		"Optional backup filename for config entries")
+	cmdFlags.StringVar(&c.config.intentionFileName, "intentions", "",
+		"Optional backup filename for intentions")
+	cmdFlags.StringVar(&c.config.bundle, "bundle", "",
+		"Optional list of sections to write to a single bundle file")
 	cmdFlags.StringVar(&c.config.pathTransform, "transform", "",
 		"Optional path transformation")
+	cmdFlags.StringVar(&c.config.incremental, "incremental", "",
+		"Optional location of a previous bundle")
+	cmdFlags.StringVar(&c.config.identity, "identity", "",
+		"Optional identity file used to read the previous bundle")
 	cmdFlags.StringVar(&c.config.consulPrefix, "prefix", "/",
 		"Optional prefix from under which all keys will be fetched")
 
@@ -58,6 +88,49 @@ func (c *Command) setupFlags(args []string) error {
 		return cc.ErrUnknownArg
 	}
 
+	// only the destination may be streamed
+	if err = cc.CheckStdio(c.config.sigName, c.config.aclFileName, c.config.queryFileName,
+		c.config.configFileName, c.config.intentionFileName, c.config.incremental); err != nil {
+		return err
+	}
+
+	// build encryption keys
+	c.config.keys = common.NewKeys(c.config.cryptKey)
+	if c.config.recipients != "" {
+		if err = c.config.keys.AddRecipients(c.config.recipients); err != nil {
+			return err
+		}
+	}
+	if c.config.signKey != "" {
+		if err = c.config.keys.AddSigningKey(c.config.signKey); err != nil {
+			return err
+		}
+	}
+
+	// the hmac is keyed by a known passphrase when encrypting to recipients without one
+	if c.config.recipients != "" && c.config.signKey == "" && c.config.cryptKey == defaultCryptKey {
+		c.Log.Printf("[Warning] Data is encrypted to recipients without a 'sign-key' or 'key'.  " +
+			"The signature is an HMAC keyed by the default passphrase which anyone can " +
+			"forge after tampering with the backup.  Pass 'sign-key' or a secret 'key'.")
+	}
+	if c.config.identity != "" {
+		if err = c.config.keys.AddIdentities(c.config.identity); err != nil {
+			return err
+		}
+	}
+
+	// increments are always written as bundles
+	if c.config.incremental != "" && c.config.bundle == "" {
+		c.config.bundle = common.SectionKV
+	}
+
+	// map bundle sections onto the destination file
+	if c.config.bundle != "" {
+		if err = c.setupBundle(); err != nil {
+			return err
+		}
+	}
+
 	// populate potentially missing config items
 	cc.AddEnvDefaults(c.config.consulConfig)
 
@@ -69,3 +142,104 @@ func (c *Command) setupFlags(args []string) error {
 	// always okay
 	return nil
 }
+
+// setupBundle points all requested sections at the bundle destination
+func (c *Command) setupBundle() error {
+	var sections map[string]bool // requested sections
+	var err error                // error holder
+
+	// separate files make no sense with a bundle
+	if c.config.aclFileName != "" || c.config.queryFileName != "" ||
+		c.config.configFileName != "" || c.config.intentionFileName != "" {
+		return ErrBundleFiles
+	}
+
+	// parse sections
+	if sections, err = common.ParseSections(c.config.bundle); err != nil {
+		return err
+	}
+
+	// point sections at destination
+	c.config.noKV = !sections[common.SectionKV]
+	if c.config.noKV && c.config.incremental != "" {
+		return ErrIncrementalKV
+	}
+	c.config.aclFileName = bundleFile(sections[common.SectionACLs], c.config.fileName)
+	c.config.queryFileName = bundleFile(sections[common.SectionQueries], c.config.fileName)
+	c.config.configFileName = bundleFile(sections[common.SectionConfigs], c.config.fileName)
+	c.config.intentionFileName = bundleFile(sections[common.SectionIntentions], c.config.fileName)
+
+	// all good
+	return nil
+}
+
+// bundleFile returns the bundle file name for included sections
+func bundleFile(included bool, fname string) string {
+	if included {
+		return fname
+	}
+	return ""
+}
+
+// buildManifest returns a bundle manifest describing the source cluster
+func (c *Command) buildManifest() *common.Manifest {
+	var m *common.Manifest                     // output manifest
+	var self map[string]map[string]interface{} // agent information
+	var err error                              // error holder
+
+	// init manifest
+	m = &common.Manifest{
+		Version:    c.Version,
+		Datacenter: c.config.consulConfig.Datacenter,
+		Timestamp:  time.Now().UTC(),
+		Prefix:     c.config.consulPrefix,
+		Signer:     c.config.keys.SignerFingerprint(),
+	}
+
+	// get datacenter from the agent if not passed
+	if m.Datacenter == "" {
+		if self, err = c.consulClient.Agent().Self(); err != nil {
+			c.Log.Printf("[Warning] Failed to read agent datacenter: %s", err.Error())
+		} else if dc, ok := self["Config"]["Datacenter"].(string); ok {
+			m.Datacenter = dc
+		}
+	}
+
+	// get current leader
+	if m.Leader, err = c.consulClient.Status().Leader(); err != nil {
+		c.Log.Printf("[Warning] Failed to read cluster leader: %s", err.Error())
+	}
+
+	// return manifest
+	return m
+}
+
+// writeSection adds data to the bundle when writing a bundle
+// or writes data to the passed destination
+func (c *Command) writeSection(name, dest string, count int, data []byte) error {
+	// check bundle
+	if c.bundle != nil {
+		c.bundle.Add(name, count, data)
+		return nil
+	}
+	// write data to destination
+	return common.WriteData(dest, c.sigName(dest), c.config.keys, data)
+}
+
+// sigName returns the signature location for a destination
+// which is only set for the primary destination
+func (c *Command) sigName(dest string) string {
+	if dest == c.config.fileName {
+		return c.config.sigName
+	}
+	return ""
+}
+
+// messages returns the writer used for messages that are not logged
+// which is stderr when the backup is streamed to stdout
+func (c *Command) messages() io.Writer {
+	if common.IsStdio(c.config.fileName) {
+		return os.Stderr
+	}
+	return os.Stdout
+}
@@ -0,0 +1,110 @@
+package diff
+
+import (
+	"fmt"
+	stdLog "log"
+
+	"github.com/myENA/consul-backinator/common"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+	ct "github.com/myENA/consul-backinator/common/transformer"
+)
+
+// primary configuration
+type config struct {
+	fileName      string
+	sigName       string
+	against       string
+	cryptKey      string
+	identity      string
+	verifyKey     string
+	keys          *common.Keys
+	pathTransform string
+	consulPrefix  string
+	showValues    bool
+	jsonOutput    bool
+	consulConfig  *ccns.Config
+}
+
+// Command is a Command implementation that runs the diff operation
+type Command struct {
+	Self            string
+	Log             *stdLog.Logger
+	config          *config
+	consulClient    *ccns.Client
+	pathTransformer *ct.PathTransformer
+}
+
+// Run is a function to run the command
+func (c *Command) Run(args []string) int {
+	var err error // error holder
+
+	// setup flags
+	if err = c.setupFlags(args); err != nil {
+		c.Log.Printf("[Error] Setup failed: %s", err.Error())
+		return 1
+	}
+
+	// build client when comparing against a live cluster
+	if c.config.against == "" {
+		if c.consulClient, err = c.config.consulConfig.New(); err != nil {
+			c.Log.Printf("[Error] Failed initialize consul client: %s", err.Error())
+			return 1
+		}
+	}
+
+	// build transformer if needed
+	if c.pathTransformer, err = ct.New(c.config.pathTransform); err != nil {
+		c.Log.Printf("[Error] Failed to initialize path transformer: %s", err.Error())
+		return 1
+	}
+
+	// compare keys
+	if err = c.diffKeys(); err != nil {
+		c.Log.Printf("[Error] Failed to compare key data: %s", err.Error())
+		return 1
+	}
+
+	// exit clean
+	return 0
+}
+
+// Synopsis shows the command summary
+func (c *Command) Synopsis() string {
+	return "Compare a backup file against a cluster or another backup"
+}
+
+// Help shows the detailed command options
+func (c *Command) Help() string {
+	return fmt.Sprintf(`Usage: %s diff [options]
+
+	Show the key changes a restore of a backup file would make
+	to a consul cluster or the differences between two backup files.
+
+Options:
+
+	-file            Source filename, S3 location or "-" to read from stdin (default: "consul.bak")
+	-sig             Optional signature location used instead of next to the source or embedded in the stdin stream
+	-against         Optional backup filename or S3 location to compare against instead of the cluster
+	-key             Passphrase for data encryption and signature validation (default: "password")
+	-identity        Optional age identity file containing private keys for public key encrypted data
+	-verify-key      Optional PEM encoded ed25519 public key file used to validate signed data
+	-transform       Optional path transformation (oldPath,newPath...)
+	-prefix          Optional prefix under which keys will be compared (default: "/")
+	-values          Show unified diffs of modified text values
+	-json            Print the differences as JSON
+	-addr            Optional consul address and port (default: "127.0.0.1:8500")
+	-scheme          Optional consul scheme ("http" or "https")
+	-dc              Optional consul datacenter
+	-token           Optional consul access token
+	-ca-cert         Optional path to a PEM encoded CA cert file
+	-client-cert     Optional path to a PEM encoded client certificate
+	-client-key      Optional path to an unencrypted PEM encoded private key
+	-tls-skip-verify Optional bool for verifying a TLS certificate (not recommended)
+
+	Removed keys are only deleted by a restore with the delete option.
+
+Please see documentation on GitHub for a detailed explanation of all options.
+https://github.com/myENA/consul-backinator
+
+`, c.Self)
+}
@@ -0,0 +1,150 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/kv"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffKeys compares the keys in the backup file against the
+// live cluster or a second backup file and prints the changes
+func (c *Command) diffKeys() error {
+	var current, desired api.KVPairs // compared key sets
+	var changes *kv.Changes          // key changes
+	var err error                    // general error holder
+
+	// read backup keys as they would be restored
+	if desired, err = c.readKeys(c.config.fileName); err != nil {
+		return err
+	}
+	c.pathTransformer.Transform(desired)
+	desired = kv.Filter(desired, c.config.consulPrefix)
+
+	// read keys to compare against
+	if c.config.against != "" {
+		if current, err = c.readKeys(c.config.against); err != nil {
+			return err
+		}
+		current = kv.Filter(current, c.config.consulPrefix)
+	} else if current, _, err = c.consulClient.KV().List(c.config.consulPrefix, nil); err != nil {
+		return err
+	}
+
+	// compare keys
+	changes = kv.Compare(current, desired)
+
+	// build value diffs if requested
+	if c.config.showValues {
+		for _, change := range changes.Modified {
+			if change.Diff, err = valueDiff(change); err != nil {
+				return err
+			}
+		}
+	}
+
+	// print changes
+	return c.printChanges(changes)
+}
+
+// readKeys reads and decodes keys from a backup file or bundle
+func (c *Command) readKeys(src string) (api.KVPairs, error) {
+	var data []byte // read json data
+	var err error   // general error holder
+
+	// read json data from source
+	if data, err = common.ReadSection(src, c.sigName(src), c.config.keys, common.SectionKV); err != nil {
+		return nil, err
+	}
+
+	// decode and return
+	return kv.Decode(data)
+}
+
+// sigName returns the signature location for a source
+// which is only set for the primary source
+func (c *Command) sigName(src string) string {
+	if src == c.config.fileName {
+		return c.config.sigName
+	}
+	return ""
+}
+
+// valueDiff returns a unified diff of a modified value
+// or an empty string when either value is not text
+func valueDiff(change *kv.Change) (string, error) {
+	// only diff text
+	if !isText(change.OldValue) || !isText(change.NewValue) ||
+		bytes.Equal(change.OldValue, change.NewValue) {
+		return "", nil
+	}
+
+	// build and return diff
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(change.OldValue)),
+		B:        difflib.SplitLines(string(change.NewValue)),
+		FromFile: "current/" + change.Key,
+		ToFile:   "backup/" + change.Key,
+		Context:  3,
+	})
+}
+
+// isText checks if a value is valid utf8 without null bytes
+func isText(value []byte) bool {
+	return utf8.Valid(value) && bytes.IndexByte(value, 0) == -1
+}
+
+// printChanges prints the key changes to stdout
+func (c *Command) printChanges(changes *kv.Changes) error {
+	var data []byte // encoded changes
+	var err error   // general error holder
+
+	// check output format
+	if c.config.jsonOutput {
+		// encode changes
+		if data, err = json.MarshalIndent(changes, "", "  "); err != nil {
+			return err
+		}
+		// write payload
+		os.Stdout.Write(data)
+		// write a blank line
+		os.Stdout.WriteString("\n")
+		// all done
+		return nil
+	}
+
+	// print added keys
+	for _, key := range changes.Added {
+		fmt.Printf("+ %s\n", key)
+	}
+
+	// print removed keys
+	for _, key := range changes.Removed {
+		fmt.Printf("- %s\n", key)
+	}
+
+	// print modified keys
+	for _, change := range changes.Modified {
+		if change.OldFlags != change.NewFlags {
+			fmt.Printf("~ %s (flags %d -> %d)\n", change.Key, change.OldFlags, change.NewFlags)
+		} else {
+			fmt.Printf("~ %s\n", change.Key)
+		}
+		if change.Diff != "" {
+			fmt.Print(change.Diff)
+		}
+	}
+
+	// print summary
+	fmt.Printf("%d added, %d removed, %d modified\n",
+		len(changes.Added), len(changes.Removed), len(changes.Modified))
+
+	// okay
+	return nil
+}
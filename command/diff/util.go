@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/myENA/consul-backinator/common"
+	cc "github.com/myENA/consul-backinator/common/config"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+)
+
+// setupFlags initializes the instance configuration
+func (c *Command) setupFlags(args []string) error {
+	var cmdFlags *flag.FlagSet // instance flagset
+
+	// init config if needed
+	if c.config == nil {
+		c.config = new(config)
+	}
+
+	// init consul config if needed
+	if c.config.consulConfig == nil {
+		c.config.consulConfig = new(ccns.Config)
+	}
+
+	// init flagset
+	cmdFlags = flag.NewFlagSet("diff", flag.ContinueOnError)
+	cmdFlags.Usage = func() { fmt.Fprint(os.Stdout, c.Help()); os.Exit(0) }
+
+	// declare flags
+	cmdFlags.StringVar(&c.config.fileName, "file", "consul.bak",
+		"Source")
+	cmdFlags.StringVar(&c.config.sigName, "sig", "",
+		"Optional signature location")
+	cmdFlags.StringVar(&c.config.against, "against", "",
+		"Optional backup to compare against instead of the cluster")
+	cmdFlags.StringVar(&c.config.cryptKey, "key", "password",
+		"Passphrase for data encryption and signature validation")
+	cmdFlags.StringVar(&c.config.identity, "identity", "",
+		"Optional identity file for public key encrypted data")
+	cmdFlags.StringVar(&c.config.verifyKey, "verify-key", "",
+		"Optional public key file used to validate signed data")
+	cmdFlags.StringVar(&c.config.pathTransform, "transform", "",
+		"Optional path transformation")
+	cmdFlags.StringVar(&c.config.consulPrefix, "prefix", "/",
+		"Optional prefix under which keys will be compared")
+	cmdFlags.BoolVar(&c.config.showValues, "values", false,
+		"Show unified diffs of modified text values")
+	cmdFlags.BoolVar(&c.config.jsonOutput, "json", false,
+		"Print the differences as JSON")
+
+	// add shared flags
+	cc.AddSharedConsulFlags(cmdFlags, c.config.consulConfig)
+
+	// parse flags and ignore error
+	if err := cmdFlags.Parse(args); err != nil {
+		return nil
+	}
+
+	// check for remaining garbage
+	if cmdFlags.NArg() > 0 {
+		return cc.ErrUnknownArg
+	}
+
+	// only the source may be streamed
+	if err := cc.CheckStdio(c.config.sigName, c.config.against); err != nil {
+		return err
+	}
+
+	// build decryption keys
+	c.config.keys = common.NewKeys(c.config.cryptKey)
+	if c.config.identity != "" {
+		if err := c.config.keys.AddIdentities(c.config.identity); err != nil {
+			return err
+		}
+	}
+	if c.config.verifyKey != "" {
+		if err := c.config.keys.AddVerifyKey(c.config.verifyKey); err != nil {
+			return err
+		}
+	}
+
+	// populate potentially missing config items
+	cc.AddEnvDefaults(c.config.consulConfig)
+
+	// fixup prefix per upstream issue 2403
+	// https://github.com/hashicorp/consul/issues/2403
+	c.config.consulPrefix = strings.TrimPrefix(c.config.consulPrefix,
+		ccns.Separator)
+
+	// always okay
+	return nil
+}
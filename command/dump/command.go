@@ -3,16 +3,25 @@ package dump
 import (
 	"fmt"
 	stdLog "log"
+
+	"github.com/myENA/consul-backinator/common"
 )
 
 // primary configuration
 type config struct {
 	fileName      string
+	sigName       string
 	cryptKey      string
+	identity      string
+	verifyKey     string
+	keys          *common.Keys
 	pathTransform string
 	plainDump     bool
 	acls          bool
 	queries       bool
+	configs       bool
+	intentions    bool
+	manifest      bool
 }
 
 // Command is a Command implementation that runs the backup operation
@@ -55,11 +64,20 @@ func (c *Command) Help() string {
 
 Options:
 
-	-file         Source filename (default: "consul.bak")
+	-file         Source filename or "-" to read from stdin (default: "consul.bak")
+	-sig          Optional signature location used instead of next to the source or embedded in the stdin stream
 	-key          Passphrase for data encryption and signature validation (default: "password")
+	-identity     Optional age identity file containing private keys for public key encrypted data
+	-verify-key   Optional PEM encoded ed25519 public key file used to validate signed data
 	-plain        Dump a reduced set of information
-	-acls         Specified file is an ACL token backup file
+	-acls         Specified file is an ACL backup file
 	-queries      Specified file is a prepared query backup file (consider using plain for query files)
+	-configs      Specified file is a config entry backup file
+	-intentions   Specified file is an intention backup file
+	-manifest     Dump the manifest of a bundle file
+
+	When the specified file is a bundle the section matching the
+	acls, queries, configs or intentions option is dumped (default: kv).
 
 Please see documentation on GitHub for a detailed explanation of all options.
 https://github.com/myENA/consul-backinator
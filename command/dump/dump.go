@@ -2,26 +2,57 @@ package dump
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/acl"
+	"github.com/myENA/consul-backinator/common/configentry"
+	"github.com/myENA/consul-backinator/common/intention"
+	"github.com/myENA/consul-backinator/common/kv"
 )
 
+// ErrNotBundle is returned when requesting the manifest of a backup that is not a bundle
+var ErrNotBundle = errors.New("Specified file is not a bundle")
+
 // dumpData reads data from a backup file and prints to stdout
 func (c *Command) dumpData() error {
 	var kvps api.KVPairs                       // kv pairs
-	var acls []*api.ACLEntry                   // acl entries
+	var snap *acl.Snapshot                     // acl snapshot
+	var acls []*api.ACLEntry                   // legacy acl entries
 	var queries []*api.PreparedQueryDefinition // query definitions
+	var entries []api.ConfigEntry              // config entries
+	var ixns *intention.Snapshot               // intention snapshot
+	var inc *kv.Increment                      // kv increment
+	var bundle *common.Bundle                  // bundle
 	var data []byte                            // read json data
 	var err error                              // general error holder
 
 	// read json data from source
-	if data, err = common.ReadData(c.config.fileName, c.config.cryptKey); err != nil {
+	if data, err = common.ReadData(c.config.fileName, c.config.sigName, c.config.keys); err != nil {
 		return err
 	}
 
+	// check for bundle
+	if common.IsBundle(data) {
+		// decode bundle
+		if bundle, err = common.DecodeBundle(data); err != nil {
+			return err
+		}
+		// print manifest if requested
+		if c.config.manifest {
+			return c.dumpManifest(bundle.Manifest)
+		}
+		// continue with the requested section
+		if data, err = bundle.Section(c.section()); err != nil {
+			return err
+		}
+	} else if c.config.manifest {
+		return ErrNotBundle
+	}
+
 	// check plain
 	if !c.config.plainDump {
 		// write payload
@@ -35,12 +66,36 @@ func (c *Command) dumpData() error {
 	switch {
 	case c.config.acls:
 		// decode acl data
-		if err = json.Unmarshal(data, &acls); err != nil {
+		if snap, acls, err = acl.Decode(data); err != nil {
 			return err
 		}
-		// loop through and print acls
-		for _, acl := range acls {
-			fmt.Printf("Token: %s (%s)\n%s\n", acl.Name, acl.Type, acl.Rules)
+		// check for an older backup
+		if snap == nil {
+			// loop through and print legacy acls
+			for _, entry := range acls {
+				fmt.Printf("Token: %s (%s)\n%s\n", entry.Name, entry.Type, entry.Rules)
+			}
+			break
+		}
+		// loop through and print policies
+		for _, policy := range snap.Policies {
+			fmt.Printf("Policy: %s (%s)\n%s\n", policy.Name, policy.ID, policy.Rules)
+		}
+		// loop through and print roles
+		for _, role := range snap.Roles {
+			fmt.Printf("Role: %s (%s)\n", role.Name, role.ID)
+		}
+		// loop through and print auth methods
+		for _, method := range snap.AuthMethods {
+			fmt.Printf("Auth Method: %s (%s)\n", method.Name, method.Type)
+		}
+		// loop through and print binding rules
+		for _, rule := range snap.BindingRules {
+			fmt.Printf("Binding Rule: %s %s %s\n", rule.AuthMethod, rule.BindType, rule.BindName)
+		}
+		// loop through and print tokens
+		for _, token := range snap.Tokens {
+			fmt.Printf("Token: %s (%s)\n", token.Description, token.AccessorID)
 		}
 	case c.config.queries:
 		// decode acl data
@@ -51,6 +106,37 @@ func (c *Command) dumpData() error {
 		for _, query := range queries {
 			fmt.Printf("Query: %s %s\n", query.ID, query.Token)
 		}
+	case c.config.configs:
+		// decode config entry data
+		if entries, err = configentry.Decode(data); err != nil {
+			return err
+		}
+		// loop through and print entries
+		for _, entry := range entries {
+			fmt.Printf("Config Entry: %s/%s\n", entry.GetKind(), entry.GetName())
+		}
+	case c.config.intentions:
+		// decode intention data
+		if ixns, err = intention.Decode(data); err != nil {
+			return err
+		}
+		// loop through and print intentions
+		for _, ixn := range ixns.Intentions {
+			fmt.Printf("Intention: %s\n", ixn.String())
+		}
+	case kv.IsIncrement(data):
+		// decode increment
+		if inc, err = kv.DecodeIncrement(data); err != nil {
+			return err
+		}
+		// loop through and print changed keys and tombstones
+		fmt.Printf("Since: %d\n", inc.Since)
+		for _, pair := range inc.Pairs {
+			fmt.Printf("Key: %s\n%s\n", pair.Key, pair.Value)
+		}
+		for _, key := range inc.Deleted {
+			fmt.Printf("Deleted: %s\n", key)
+		}
 	default:
 		// decode kv data
 		if err = json.Unmarshal(data, &kvps); err != nil {
@@ -65,3 +151,63 @@ func (c *Command) dumpData() error {
 	// okay
 	return nil
 }
+
+// dumpManifest prints a bundle manifest to stdout
+func (c *Command) dumpManifest(m *common.Manifest) error {
+	var data []byte // encoded manifest
+	var err error   // general error holder
+
+	// check plain
+	if !c.config.plainDump {
+		// encode manifest
+		if data, err = json.MarshalIndent(m, "", "  "); err != nil {
+			return err
+		}
+		// write payload
+		os.Stdout.Write(data)
+		// write a blank line
+		os.Stdout.WriteString("\n")
+		// all done
+		return nil
+	}
+
+	// print summary
+	fmt.Printf("Version: %s\nDatacenter: %s\nLeader: %s\nIndex: %d\nTimestamp: %s\nPrefix: %s\n",
+		m.Version, m.Datacenter, m.Leader, m.Index, m.Timestamp, m.Prefix)
+
+	// print parent if incremental
+	if m.Parent != "" {
+		fmt.Printf("Since: %d\nParent: %s\n", m.Since, m.Parent)
+	}
+
+	// print signer if signed with a private key
+	if m.Signer != "" {
+		fmt.Printf("Signer: %s\n", m.Signer)
+	}
+
+	// print sections in order
+	for _, name := range common.Sections {
+		if info, ok := m.Sections[name]; ok {
+			fmt.Printf("Section: %s (%d items, %d bytes)\n", name, info.Count, info.Size)
+		}
+	}
+
+	// okay
+	return nil
+}
+
+// section returns the bundle section matching the requested data type
+func (c *Command) section() string {
+	switch {
+	case c.config.acls:
+		return common.SectionACLs
+	case c.config.queries:
+		return common.SectionQueries
+	case c.config.configs:
+		return common.SectionConfigs
+	case c.config.intentions:
+		return common.SectionIntentions
+	default:
+		return common.SectionKV
+	}
+}
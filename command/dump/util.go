@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/myENA/consul-backinator/common"
 	cc "github.com/myENA/consul-backinator/common/config"
 )
 
@@ -24,14 +25,26 @@ func (c *Command) setupFlags(args []string) error {
 	// declare flags
 	cmdFlags.StringVar(&c.config.fileName, "file", "consul.bak",
 		"Destination file target")
+	cmdFlags.StringVar(&c.config.sigName, "sig", "",
+		"Optional signature location")
 	cmdFlags.StringVar(&c.config.cryptKey, "key", "password",
 		"Passphrase for data encryption and signature validation")
+	cmdFlags.StringVar(&c.config.identity, "identity", "",
+		"Optional identity file for public key encrypted data")
+	cmdFlags.StringVar(&c.config.verifyKey, "verify-key", "",
+		"Optional public key file used to validate signed data")
 	cmdFlags.BoolVar(&c.config.plainDump, "plain", false,
 		"Dump a reduced set of information")
 	cmdFlags.BoolVar(&c.config.acls, "acls", false,
-		"Specified file is an ACL token backup file")
+		"Specified file is an ACL backup file")
 	cmdFlags.BoolVar(&c.config.queries, "queries", false,
 		"Specified file is a prepared query backup file")
+	cmdFlags.BoolVar(&c.config.configs, "configs", false,
+		"Specified file is a config entry backup file")
+	cmdFlags.BoolVar(&c.config.intentions, "intentions", false,
+		"Specified file is an intention backup file")
+	cmdFlags.BoolVar(&c.config.manifest, "manifest", false,
+		"Dump the manifest of a bundle file")
 
 	// parse flags and ignore error
 	if err := cmdFlags.Parse(args); err != nil {
@@ -43,6 +56,24 @@ func (c *Command) setupFlags(args []string) error {
 		return cc.ErrUnknownArg
 	}
 
+	// only the source may be streamed
+	if err := cc.CheckStdio(c.config.sigName); err != nil {
+		return err
+	}
+
+	// build decryption keys
+	c.config.keys = common.NewKeys(c.config.cryptKey)
+	if c.config.identity != "" {
+		if err := c.config.keys.AddIdentities(c.config.identity); err != nil {
+			return err
+		}
+	}
+	if c.config.verifyKey != "" {
+		if err := c.config.keys.AddVerifyKey(c.config.verifyKey); err != nil {
+			return err
+		}
+	}
+
 	// always okay
 	return nil
 }
@@ -0,0 +1,79 @@
+package list
+
+import (
+	"fmt"
+	stdLog "log"
+
+	"github.com/myENA/consul-backinator/common"
+)
+
+// primary configuration
+type config struct {
+	location  string
+	match     string
+	pattern   string
+	cryptKey  string
+	identity  string
+	verifyKey string
+	keys      *common.Keys
+}
+
+// Command is a Command implementation that runs the list operation
+type Command struct {
+	Self   string
+	Log    *stdLog.Logger
+	config *config
+}
+
+// Run is a function to run the command
+func (c *Command) Run(args []string) int {
+	var count int // listed backups
+	var err error // error holder
+
+	// setup flags
+	if err = c.setupFlags(args); err != nil {
+		c.Log.Printf("[Error] Setup failed: %s", err.Error())
+		return 1
+	}
+
+	// list backups
+	if count, err = c.list(); err != nil {
+		c.Log.Printf("[Error] Failed to list backups: %s", err.Error())
+		return 1
+	}
+
+	// show success
+	c.Log.Printf("[Success] Found %d backups matching %s", count, c.config.pattern)
+
+	// exit clean
+	return 0
+}
+
+// Synopsis shows the command summary
+func (c *Command) Synopsis() string {
+	return "List backups in a directory or S3 location"
+}
+
+// Help shows the detailed command options
+func (c *Command) Help() string {
+	return fmt.Sprintf(`Usage: %s list [options]
+
+	Lists backups in a local directory or S3 prefix newest first.
+
+Options:
+
+	-path            Local directory or S3 location prefix (default: ".")
+	-match           Backup file name pattern (default: "*.bak")
+	-key             Optional passphrase used to read the datacenter and key count of each backup
+	-identity        Optional age identity file containing private keys for public key encrypted data
+	-verify-key      Optional PEM encoded ed25519 public key file used to validate signed data
+
+	The datacenter and key count are read from the manifest stored inside the
+	encrypted backup so they are only shown for signed backups when one of the
+	key, identity or verify-key options is passed.
+
+Please see documentation on GitHub for a detailed explanation of all options.
+https://github.com/myENA/consul-backinator
+
+`, c.Self)
+}
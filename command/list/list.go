@@ -0,0 +1,77 @@
+package list
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/kv"
+)
+
+// unknown is shown for details that could not be read
+const unknown = "-"
+
+// list prints the backups matching the pattern and returns the count
+func (c *Command) list() (int, error) {
+	var backups []*common.BackupInfo // found backups
+	var tw *tabwriter.Writer         // output writer
+	var err error                    // general error holder
+
+	// find backups
+	if backups, err = common.ListBackups(c.config.pattern); err != nil {
+		return 0, err
+	}
+
+	// init
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	// print backups newest first
+	fmt.Fprintln(tw, "MODIFIED\tSIZE\tSIGNED\tDATACENTER\tKEYS\tNAME")
+	for _, b := range backups {
+		var dc, keys = unknown, unknown // backup details
+		if c.config.keys != nil && b.Signed {
+			dc, keys = c.details(b.Name)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%t\t%s\t%s\t%s\n",
+			b.Modified.UTC().Format(time.RFC3339), b.Size, b.Signed, dc, keys, b.Name)
+	}
+
+	// flush output
+	return len(backups), tw.Flush()
+}
+
+// details reads a backup and returns the source datacenter and key count
+func (c *Command) details(name string) (string, string) {
+	var data []byte           // decoded data
+	var bundle *common.Bundle // decoded bundle
+	var kvps api.KVPairs      // decoded pairs
+	var err error             // general error holder
+
+	// read data
+	if data, err = common.ReadData(name, "", c.config.keys); err != nil {
+		c.Log.Printf("[Warning] Failed to read %s: %s", name, err.Error())
+		return unknown, unknown
+	}
+
+	// bundles carry both in the manifest
+	if common.IsBundle(data) {
+		if bundle, err = common.DecodeBundle(data); err != nil {
+			c.Log.Printf("[Warning] Failed to decode %s: %s", name, err.Error())
+			return unknown, unknown
+		}
+		if info, ok := bundle.Manifest.Sections[common.SectionKV]; ok {
+			return bundle.Manifest.Datacenter, strconv.Itoa(info.Count)
+		}
+		return bundle.Manifest.Datacenter, unknown
+	}
+
+	// other backups only carry keys
+	if kvps, err = kv.Decode(data); err != nil {
+		return unknown, unknown
+	}
+	return unknown, strconv.Itoa(len(kvps))
+}
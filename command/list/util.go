@@ -0,0 +1,76 @@
+package list
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/myENA/consul-backinator/common"
+	cc "github.com/myENA/consul-backinator/common/config"
+)
+
+// ErrMissingMatch is returned when the match option is empty
+var ErrMissingMatch = errors.New("The 'match' option must not be empty")
+
+// setupFlags initializes the instance configuration
+func (c *Command) setupFlags(args []string) error {
+	var cmdFlags *flag.FlagSet // instance flagset
+	var err error              // error holder
+
+	// init config if needed
+	if c.config == nil {
+		c.config = new(config)
+	}
+
+	// init flagset
+	cmdFlags = flag.NewFlagSet("list", flag.ContinueOnError)
+	cmdFlags.Usage = func() { fmt.Fprint(os.Stdout, c.Help()); os.Exit(0) }
+
+	// declare flags
+	cmdFlags.StringVar(&c.config.location, "path", ".",
+		"Local directory or S3 location prefix")
+	cmdFlags.StringVar(&c.config.match, "match", "*.bak",
+		"Backup file name pattern")
+	cmdFlags.StringVar(&c.config.cryptKey, "key", "",
+		"Optional passphrase used to read backup contents")
+	cmdFlags.StringVar(&c.config.identity, "identity", "",
+		"Optional identity file for public key encrypted data")
+	cmdFlags.StringVar(&c.config.verifyKey, "verify-key", "",
+		"Optional public key file used to validate signed data")
+
+	// parse flags and ignore error
+	if err = cmdFlags.Parse(args); err != nil {
+		return nil
+	}
+
+	// check for remaining garbage
+	if cmdFlags.NArg() > 0 {
+		return cc.ErrUnknownArg
+	}
+
+	// build pattern
+	if c.config.match == "" {
+		return ErrMissingMatch
+	}
+	c.config.pattern = common.JoinPattern(c.config.location, c.config.match)
+
+	// build decryption keys when reading contents
+	if c.config.cryptKey == "" && c.config.identity == "" && c.config.verifyKey == "" {
+		return nil
+	}
+	c.config.keys = common.NewKeys(c.config.cryptKey)
+	if c.config.identity != "" {
+		if err = c.config.keys.AddIdentities(c.config.identity); err != nil {
+			return err
+		}
+	}
+	if c.config.verifyKey != "" {
+		if err = c.config.keys.AddVerifyKey(c.config.verifyKey); err != nil {
+			return err
+		}
+	}
+
+	// always okay
+	return nil
+}
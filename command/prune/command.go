@@ -0,0 +1,85 @@
+package prune
+
+import (
+	"fmt"
+	stdLog "log"
+
+	"github.com/myENA/consul-backinator/common"
+)
+
+// primary configuration
+type config struct {
+	location  string
+	match     string
+	pattern   string
+	retention *common.Retention
+	dryRun    bool
+}
+
+// Command is a Command implementation that runs the prune operation
+type Command struct {
+	Self   string
+	Log    *stdLog.Logger
+	config *config
+}
+
+// Run is a function to run the command
+func (c *Command) Run(args []string) int {
+	var count, total int // removed and found backups
+	var err error        // error holder
+
+	// setup flags
+	if err = c.setupFlags(args); err != nil {
+		c.Log.Printf("[Error] Setup failed: %s", err.Error())
+		return 1
+	}
+
+	// prune backups
+	if count, total, err = c.prune(); err != nil {
+		c.Log.Printf("[Error] Failed to prune backups: %s", err.Error())
+		return 1
+	}
+
+	// show success
+	if c.config.dryRun {
+		c.Log.Printf("[Success] Would remove %d of %d backups matching %s",
+			count, total, c.config.pattern)
+	} else {
+		c.Log.Printf("[Success] Removed %d of %d backups matching %s",
+			count, total, c.config.pattern)
+	}
+
+	// exit clean
+	return 0
+}
+
+// Synopsis shows the command summary
+func (c *Command) Synopsis() string {
+	return "Remove backups outside a retention policy"
+}
+
+// Help shows the detailed command options
+func (c *Command) Help() string {
+	return fmt.Sprintf(`Usage: %s prune [options]
+
+	Removes backups and their signatures from a local directory or S3
+	prefix that are outside the requested retention policy.
+
+Options:
+
+	-path            Local directory or S3 location prefix (default: ".")
+	-match           Backup file name pattern (default: "*.bak")
+	-keep-last       Number of most recent backups to keep (default: 0)
+	-keep-within     Keep backups modified within this duration (default: 0)
+	-keep-daily      Number of days to keep the newest backup of (default: 0)
+	-keep-weekly     Number of weeks to keep the newest backup of (default: 0)
+	-keep-monthly    Number of months to keep the newest backup of (default: 0)
+	-dry-run         List the backups that would be kept and removed without removing anything
+
+	At least one keep option is required.  Backups kept by any option are kept.
+
+Please see documentation on GitHub for a detailed explanation of all options.
+https://github.com/myENA/consul-backinator
+
+`, c.Self)
+}
@@ -0,0 +1,70 @@
+package prune
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/myENA/consul-backinator/common"
+)
+
+// prune removes backups outside the retention policy and
+// returns the number of removed and found backups
+func (c *Command) prune() (int, int, error) {
+	var backups []*common.BackupInfo // found backups
+	var expired []*common.BackupInfo // backups outside the policy
+	var count int                    // removed backups
+	var err error                    // general error holder
+
+	// find backups
+	if backups, err = common.ListBackups(c.config.pattern); err != nil {
+		return 0, 0, err
+	}
+	expired = c.config.retention.Expired(backups)
+
+	// only list backups on a dry run
+	if c.config.dryRun {
+		return len(expired), len(backups), c.printList(backups, expired)
+	}
+
+	// remove expired backups
+	for _, b := range expired {
+		if err = common.DeleteBackup(b.Name); err != nil {
+			c.Log.Printf("[Warning] Failed to remove backup %s: %s", b.Name, err.Error())
+			continue
+		}
+		c.Log.Printf("[Info] Removed backup %s", b.Name)
+		count++
+	}
+
+	// return counts
+	return count, len(backups), nil
+}
+
+// printList prints each backup with the action a prune would take
+func (c *Command) printList(backups, expired []*common.BackupInfo) error {
+	var tw *tabwriter.Writer               // output writer
+	var remove map[*common.BackupInfo]bool // expired lookup
+
+	// init
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	remove = make(map[*common.BackupInfo]bool, len(expired))
+	for _, b := range expired {
+		remove[b] = true
+	}
+
+	// print backups newest first
+	fmt.Fprintln(tw, "ACTION\tMODIFIED\tSIZE\tSIGNED\tNAME")
+	for _, b := range backups {
+		var action = "keep" // prune action
+		if remove[b] {
+			action = "remove"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%t\t%s\n", action,
+			b.Modified.UTC().Format(time.RFC3339), b.Size, b.Signed, b.Name)
+	}
+
+	// flush output
+	return tw.Flush()
+}
@@ -0,0 +1,77 @@
+package prune
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/myENA/consul-backinator/common"
+	cc "github.com/myENA/consul-backinator/common/config"
+)
+
+// Exported option errors
+var (
+	ErrMissingRetention = errors.New("At least one 'keep' option is required")
+	ErrMissingMatch     = errors.New("The 'match' option must not be empty")
+)
+
+// setupFlags initializes the instance configuration
+func (c *Command) setupFlags(args []string) error {
+	var cmdFlags *flag.FlagSet // instance flagset
+	var err error              // error holder
+
+	// init config if needed
+	if c.config == nil {
+		c.config = new(config)
+	}
+
+	// init retention
+	c.config.retention = new(common.Retention)
+
+	// init flagset
+	cmdFlags = flag.NewFlagSet("prune", flag.ContinueOnError)
+	cmdFlags.Usage = func() { fmt.Fprint(os.Stdout, c.Help()); os.Exit(0) }
+
+	// declare flags
+	cmdFlags.StringVar(&c.config.location, "path", ".",
+		"Local directory or S3 location prefix")
+	cmdFlags.StringVar(&c.config.match, "match", "*.bak",
+		"Backup file name pattern")
+	cmdFlags.IntVar(&c.config.retention.Last, "keep-last", 0,
+		"Number of most recent backups to keep")
+	cmdFlags.DurationVar(&c.config.retention.Within, "keep-within", 0,
+		"Keep backups modified within this duration")
+	cmdFlags.IntVar(&c.config.retention.Daily, "keep-daily", 0,
+		"Number of days to keep the newest backup of")
+	cmdFlags.IntVar(&c.config.retention.Weekly, "keep-weekly", 0,
+		"Number of weeks to keep the newest backup of")
+	cmdFlags.IntVar(&c.config.retention.Monthly, "keep-monthly", 0,
+		"Number of months to keep the newest backup of")
+	cmdFlags.BoolVar(&c.config.dryRun, "dry-run", false,
+		"List backups that would be kept and removed")
+
+	// parse flags and ignore error
+	if err = cmdFlags.Parse(args); err != nil {
+		return nil
+	}
+
+	// check for remaining garbage
+	if cmdFlags.NArg() > 0 {
+		return cc.ErrUnknownArg
+	}
+
+	// never remove everything by accident
+	if c.config.retention.Empty() {
+		return ErrMissingRetention
+	}
+	if c.config.match == "" {
+		return ErrMissingMatch
+	}
+
+	// build pattern
+	c.config.pattern = common.JoinPattern(c.config.location, c.config.match)
+
+	// always okay
+	return nil
+}
@@ -0,0 +1,404 @@
+package restore
+
+import (
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/acl"
+)
+
+// restoreACLs reads acl data from a backup file and restores it to consul
+func (c *Command) restoreACLs() (int, error) {
+	var snap *acl.Snapshot     // acl snapshot
+	var legacy []*api.ACLEntry // legacy acl tokens
+	var data []byte            // read json data
+	var err error              // general error holder
+
+	// read json data from source
+	if data, err = c.readSection(common.SectionACLs, c.config.aclFileName); err != nil {
+		return 0, err
+	}
+
+	// decode data
+	if snap, legacy, err = acl.Decode(data); err != nil {
+		return 0, err
+	}
+
+	// check for an older backup
+	if snap == nil {
+		return c.restoreLegacyACLs(legacy), nil
+	}
+
+	// restore in dependency order and return count
+	return c.restoreACLSnapshot(snap), nil
+}
+
+// restoreLegacyACLs restores tokens using the deprecated legacy acl endpoints
+func (c *Command) restoreLegacyACLs(acls []*api.ACLEntry) int {
+	var count int // token count
+	var err error // general error holder
+
+	// loop through acls
+	for _, entry := range acls {
+		// write token
+		if _, _, err = c.consulClient.ACL().Create(entry, nil); err != nil {
+			c.Log.Printf("[Warning] Failed to restore ACL token %s: %s",
+				entry.Name, err.Error())
+		} else {
+			// success - increment count
+			count++
+		}
+	}
+
+	// return acl count
+	return count
+}
+
+// restoreACLSnapshot restores all objects in a snapshot.  Objects are written
+// so that everything a token or role references exists before it is written.
+// Consul generates new identifiers for created policies and roles so
+// references are rewritten to point at the identifiers in the target cluster.
+func (c *Command) restoreACLSnapshot(snap *acl.Snapshot) int {
+	var policyIDs map[string]string // old to new policy identifiers
+	var roleIDs map[string]string   // old to new role identifiers
+	var count int                   // object count
+
+	// restore policies
+	policyIDs = make(map[string]string)
+	count += c.restoreACLPolicies(snap.Policies, policyIDs)
+
+	// restore roles
+	roleIDs = make(map[string]string)
+	count += c.restoreACLRoles(snap.Roles, policyIDs, roleIDs)
+
+	// restore auth methods and binding rules
+	count += c.restoreACLAuthMethods(snap.AuthMethods)
+	count += c.restoreACLBindingRules(snap.BindingRules)
+
+	// restore tokens
+	count += c.restoreACLTokens(snap.Tokens, policyIDs, roleIDs)
+
+	// return object count
+	return count
+}
+
+// restoreACLPolicies creates or updates policies matched by name
+func (c *Command) restoreACLPolicies(policies []*api.ACLPolicy, ids map[string]string) int {
+	var existing []*api.ACLPolicyListEntry // existing policies
+	var names map[string]string            // existing policy names and identifiers
+	var count int                          // policy count
+	var err error                          // general error holder
+
+	// get existing policies
+	if existing, _, err = c.consulClient.ACL().PolicyList(nil); err != nil {
+		c.Log.Printf("[Warning] Failed to list existing ACL policies: %s", err.Error())
+		return 0
+	}
+
+	// index by name
+	names = make(map[string]string, len(existing))
+	for _, entry := range existing {
+		names[entry.Name] = entry.ID
+	}
+
+	// loop through policies
+	for _, policy := range policies {
+		var written *api.ACLPolicy // written policy
+		var oldID string           // backed-up identifier
+
+		// the builtin management policy is identical everywhere
+		if policy.ID == acl.GlobalManagementPolicyID {
+			ids[policy.ID] = policy.ID
+			continue
+		}
+
+		// save backed-up id
+		oldID = policy.ID
+
+		if id, ok := names[policy.Name]; ok {
+			// update existing policy
+			policy.ID = id
+			written, _, err = c.consulClient.ACL().PolicyUpdate(policy, nil)
+		} else {
+			// remove id from backed-up policy before creating
+			policy.ID = ""
+			written, _, err = c.consulClient.ACL().PolicyCreate(policy, nil)
+		}
+
+		// check error
+		if err != nil {
+			c.Log.Printf("[Warning] Failed to restore ACL policy %s: %s",
+				policy.Name, err.Error())
+			continue
+		}
+
+		// success - record id and increment count
+		ids[oldID] = written.ID
+		count++
+	}
+
+	// return policy count
+	return count
+}
+
+// restoreACLRoles creates or updates roles matched by name
+func (c *Command) restoreACLRoles(roles []*api.ACLRole, policyIDs, ids map[string]string) int {
+	var existing []*api.ACLRole // existing roles
+	var names map[string]string // existing role names and identifiers
+	var count int               // role count
+	var err error               // general error holder
+
+	// get existing roles
+	if existing, _, err = c.consulClient.ACL().RoleList(nil); err != nil {
+		c.Log.Printf("[Warning] Failed to list existing ACL roles: %s", err.Error())
+		return 0
+	}
+
+	// index by name
+	names = make(map[string]string, len(existing))
+	for _, entry := range existing {
+		names[entry.Name] = entry.ID
+	}
+
+	// loop through roles
+	for _, role := range roles {
+		var written *api.ACLRole // written role
+		var oldID string         // backed-up identifier
+
+		// save backed-up id and update references
+		oldID = role.ID
+		remapLinks(role.Policies, policyIDs)
+
+		if id, ok := names[role.Name]; ok {
+			// update existing role
+			role.ID = id
+			written, _, err = c.consulClient.ACL().RoleUpdate(role, nil)
+		} else {
+			// remove id from backed-up role before creating
+			role.ID = ""
+			written, _, err = c.consulClient.ACL().RoleCreate(role, nil)
+		}
+
+		// check error
+		if err != nil {
+			c.Log.Printf("[Warning] Failed to restore ACL role %s: %s",
+				role.Name, err.Error())
+			continue
+		}
+
+		// success - record id and increment count
+		ids[oldID] = written.ID
+		count++
+	}
+
+	// return role count
+	return count
+}
+
+// restoreACLAuthMethods creates or updates auth methods matched by name
+func (c *Command) restoreACLAuthMethods(methods []*api.ACLAuthMethod) int {
+	var existing []*api.ACLAuthMethodListEntry // existing methods
+	var names map[string]bool                  // existing method names
+	var count int                              // method count
+	var err error                              // general error holder
+
+	// get existing methods
+	if existing, _, err = c.consulClient.ACL().AuthMethodList(nil); err != nil {
+		c.Log.Printf("[Warning] Failed to list existing ACL auth methods: %s", err.Error())
+		return 0
+	}
+
+	// index by name
+	names = make(map[string]bool, len(existing))
+	for _, entry := range existing {
+		names[entry.Name] = true
+	}
+
+	// loop through methods
+	for _, method := range methods {
+		if names[method.Name] {
+			// update existing method
+			_, _, err = c.consulClient.ACL().AuthMethodUpdate(method, nil)
+		} else {
+			// create missing method
+			_, _, err = c.consulClient.ACL().AuthMethodCreate(method, nil)
+		}
+
+		// check error
+		if err != nil {
+			c.Log.Printf("[Warning] Failed to restore ACL auth method %s: %s",
+				method.Name, err.Error())
+			continue
+		}
+
+		// success - increment count
+		count++
+	}
+
+	// return method count
+	return count
+}
+
+// restoreACLBindingRules creates binding rules that are not already present
+// on the same auth method.  Binding rules have no name so an existing rule
+// is matched by selector, bind type and bind name.
+func (c *Command) restoreACLBindingRules(rules []*api.ACLBindingRule) int {
+	var count int // rule count
+
+	// loop through rules
+	for _, rule := range rules {
+		var existing []*api.ACLBindingRule // existing rules
+		var err error                      // general error holder
+
+		// get rules for this method
+		if existing, _, err = c.consulClient.ACL().BindingRuleList(rule.AuthMethod, nil); err != nil {
+			c.Log.Printf("[Warning] Failed to read existing ACL binding rules for %s: %s",
+				rule.AuthMethod, err.Error())
+			continue
+		}
+
+		// reset id - it will be set if matched
+		rule.ID = ""
+
+		// check for a matching rule
+		for _, er := range existing {
+			if er.Selector == rule.Selector && er.BindType == rule.BindType &&
+				er.BindName == rule.BindName {
+				rule.ID = er.ID
+				break
+			}
+		}
+
+		if rule.ID != "" {
+			// update existing rule
+			_, _, err = c.consulClient.ACL().BindingRuleUpdate(rule, nil)
+		} else {
+			// create missing rule
+			_, _, err = c.consulClient.ACL().BindingRuleCreate(rule, nil)
+		}
+
+		// check error
+		if err != nil {
+			c.Log.Printf("[Warning] Failed to restore ACL binding rule for %s (%s): %s",
+				rule.AuthMethod, rule.BindName, err.Error())
+			continue
+		}
+
+		// success - increment count
+		count++
+	}
+
+	// return rule count
+	return count
+}
+
+// restoreACLTokens creates or updates tokens matched by accessor id
+// preserving both the accessor and secret identifiers
+func (c *Command) restoreACLTokens(tokens []*acl.Token, policyIDs, roleIDs map[string]string) int {
+	var existing []*api.ACLTokenListEntry // existing tokens
+	var accessors map[string]bool         // existing token accessors
+	var count int                         // token count
+	var err error                         // general error holder
+
+	// get existing tokens
+	if existing, _, err = c.consulClient.ACL().TokenList(nil); err != nil {
+		c.Log.Printf("[Warning] Failed to list existing ACL tokens: %s", err.Error())
+		return 0
+	}
+
+	// index by accessor
+	accessors = make(map[string]bool, len(existing))
+	for _, entry := range existing {
+		accessors[entry.AccessorID] = true
+	}
+
+	// loop through tokens
+	for _, token := range tokens {
+		// tokens issued by an auth method can only be created by a login
+		if token.AuthMethod != "" {
+			c.Log.Printf("[Info] Skipping ACL token %s issued by auth method %s",
+				token.AccessorID, token.AuthMethod)
+			continue
+		}
+
+		// expired tokens would be rejected
+		if token.ExpirationTime != nil && token.ExpirationTime.Before(time.Now()) {
+			c.Log.Printf("[Info] Skipping expired ACL token %s", token.AccessorID)
+			continue
+		}
+
+		// legacy tokens may only be written with the legacy endpoints
+		if token.Legacy {
+			if err = c.restoreLegacyToken(token); err != nil {
+				c.Log.Printf("[Warning] Failed to restore legacy ACL token %s: %s",
+					token.AccessorID, err.Error())
+			} else {
+				// success - increment count
+				count++
+			}
+			continue
+		}
+
+		// update references
+		remapLinks(token.Policies, policyIDs)
+		remapLinks(token.Roles, roleIDs)
+
+		if accessors[token.AccessorID] {
+			// update existing token
+			_, _, err = c.consulClient.ACL().TokenUpdate(token.ACLToken, nil)
+		} else {
+			// create missing token with backed-up identifiers
+			_, _, err = c.consulClient.ACL().TokenCreate(token.ACLToken, nil)
+		}
+
+		// check error
+		if err != nil {
+			c.Log.Printf("[Warning] Failed to restore ACL token %s: %s",
+				token.AccessorID, err.Error())
+			continue
+		}
+
+		// success - increment count
+		count++
+	}
+
+	// return token count
+	return count
+}
+
+// restoreLegacyToken writes a legacy token with the legacy endpoints
+func (c *Command) restoreLegacyToken(token *acl.Token) error {
+	var entry *api.ACLEntry // legacy token
+	var err error           // general error holder
+
+	// build legacy token
+	entry = &api.ACLEntry{
+		ID:    token.SecretID,
+		Name:  token.Description,
+		Type:  token.Type,
+		Rules: token.Rules,
+	}
+
+	// never grant management to tokens backed up without a type
+	if entry.Type == "" {
+		c.Log.Printf("[Warning] Restoring legacy ACL token '%s' without a recorded type as a client token",
+			token.Description)
+		entry.Type = api.ACLClientType
+	}
+
+	// write token
+	_, _, err = c.consulClient.ACL().Create(entry, nil)
+
+	// return write error
+	return err
+}
+
+// remapLinks rewrites link identifiers using the passed identifier map
+func remapLinks(links []*api.ACLLink, ids map[string]string) {
+	for _, link := range links {
+		if id, ok := ids[link.ID]; ok {
+			link.ID = id
+		}
+	}
+}
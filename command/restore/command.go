@@ -4,30 +4,48 @@ import (
 	"fmt"
 	stdLog "log"
 
+	"github.com/myENA/consul-backinator/common"
 	ccns "github.com/myENA/consul-backinator/common/consul"
 	ct "github.com/myENA/consul-backinator/common/transformer"
 )
 
 // primary configuration
 type config struct {
-	fileName      string
-	cryptKey      string
-	noKV          bool
-	aclFileName   string
-	queryFileName string
-	pathTransform string
-	delTree       bool
-	consulPrefix  string
-	consulConfig  *ccns.Config
+	fileName          string
+	sigName           string
+	cryptKey          string
+	identity          string
+	verifyKey         string
+	keys              *common.Keys
+	noKV              bool
+	aclFileName       string
+	queryFileName     string
+	configFileName    string
+	intentionFileName string
+	bundle            string
+	pathTransform     string
+	increments        string
+	delTree           bool
+	mirror            bool
+	dryRun            bool
+	atomic            bool
+	txnSize           int
+	snapshot          string
+	noSnapshot        bool
+	conflict          string
+	consulPrefix      string
+	consulConfig      *ccns.Config
 }
 
 // Command is a Command implementation that runs the backup operation
 type Command struct {
 	Self            string
+	Version         string
 	Log             *stdLog.Logger
 	config          *config
 	consulClient    *ccns.Client
 	pathTransformer *ct.PathTransformer
+	bundle          *common.Bundle
 }
 
 // Run is a function to run the command
@@ -41,11 +59,20 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	// read bundle if requested
+	if c.config.bundle != "" {
+		if err = c.setupBundle(); err != nil {
+			c.Log.Printf("[Error] Failed to read bundle: %s", err.Error())
+			return 1
+		}
+	}
+
 	// sanity check
-	if c.config.noKV && (c.config.aclFileName == "" && c.config.queryFileName == "") {
-		c.Log.Printf("[Error] Passing 'nokv' and an empty 'acls' and/or 'queries' file " +
-			"doesn't make any sense.  You should specify an 'acls' and/or 'queries' file " +
-			"when using the 'nokv' option.")
+	if c.config.noKV && (c.config.aclFileName == "" && c.config.queryFileName == "" &&
+		c.config.configFileName == "" && c.config.intentionFileName == "") {
+		c.Log.Printf("[Error] Passing 'nokv' and an empty 'acls', 'queries', 'configs' and/or " +
+			"'intentions' file doesn't make any sense.  You should specify an 'acls', " +
+			"'queries', 'configs' and/or 'intentions' file when using the 'nokv' option.")
 		return 1
 	}
 
@@ -61,6 +88,32 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	// only report planned changes if requested
+	if c.config.dryRun {
+		if err = c.planRestore(); err != nil {
+			c.Log.Printf("[Error] Failed to plan restore of %s", err.Error())
+			return 1
+		}
+
+		// show success
+		c.Log.Printf("[Success] Planned restore from %s to %s without making changes",
+			c.config.fileName,
+			c.config.consulConfig.Address)
+		return 0
+	}
+
+	// snapshot everything the restore may change unless otherwise requested
+	if !c.config.noSnapshot {
+		if err = c.writeSnapshot(); err != nil {
+			c.Log.Printf("[Error] %s", err.Error())
+			return 1
+		}
+
+		// show success
+		c.Log.Printf("[Success] Wrote pre-restore snapshot to %s.  "+
+			"Use the rollback command to restore it.", c.config.snapshot)
+	}
+
 	// restore keys unless otherwise requested
 	if !c.config.noKV {
 		if count, err = c.restoreKeys(); err != nil {
@@ -79,12 +132,12 @@ func (c *Command) Run(args []string) int {
 	// restore acls if requested
 	if c.config.aclFileName != "" {
 		if count, err = c.restoreACLs(); err != nil {
-			c.Log.Printf("[Error] Failed to restore ACL tokens: %s", err.Error())
+			c.Log.Printf("[Error] Failed to restore ACL data: %s", err.Error())
 			return 1
 		}
 
 		// show success
-		c.Log.Printf("[Success] Restored %d ACL tokens from %s to %s",
+		c.Log.Printf("[Success] Restored %d ACL objects from %s to %s",
 			count,
 			c.config.aclFileName,
 			c.config.consulConfig.Address)
@@ -104,6 +157,34 @@ func (c *Command) Run(args []string) int {
 			c.config.consulConfig.Address)
 	}
 
+	// restore config entries if requested
+	if c.config.configFileName != "" {
+		if count, err = c.restoreConfigEntries(); err != nil {
+			c.Log.Printf("[Error] Failed to restore config entries: %s", err.Error())
+			return 1
+		}
+
+		// show success
+		c.Log.Printf("[Success] Restored %d config entries from %s to %s",
+			count,
+			c.config.configFileName,
+			c.config.consulConfig.Address)
+	}
+
+	// restore intentions if requested
+	if c.config.intentionFileName != "" {
+		if count, err = c.restoreIntentions(); err != nil {
+			c.Log.Printf("[Error] Failed to restore intentions: %s", err.Error())
+			return 1
+		}
+
+		// show success
+		c.Log.Printf("[Success] Restored %d intentions from %s to %s",
+			count,
+			c.config.intentionFileName,
+			c.config.consulConfig.Address)
+	}
+
 	// exit clean
 	return 0
 }
@@ -121,13 +202,27 @@ func (c *Command) Help() string {
 
 Options:
 
-	-file            Source filename or S3 location (default: "consul.bak")
+	-file            Source filename, S3 location or "-" to read from stdin (default: "consul.bak")
+	-sig             Optional signature location used instead of next to the source or embedded in the stdin stream
 	-key             Passphrase for data encryption and signature validation (default: "password")
+	-identity        Optional age identity file containing private keys for public key encrypted data
+	-verify-key      Optional PEM encoded ed25519 public key file used to validate signed data
 	-nokv            Do not attempt to restore kv data
-	-acls            Optional source filename or S3 location for acl tokens
+	-acls            Optional source filename or S3 location for acl policies, roles and tokens
 	-queries         Optional source filename or S3 location for query definitions
+	-configs         Optional source filename or S3 location for config entries
+	-intentions      Optional source filename or S3 location for intentions
+	-bundle          Optional list of sections to restore from a bundle file (kv,acls,queries,configs,intentions or all)
 	-transform       Optional path transformation (oldPath,newPath...)
+	-increments      Optional list of incremental bundles to replay in order on top of the kv section of the bundle
 	-delete          Delete all keys under specified prefix prior to restoration (default: false)
+	-mirror          Delete only keys under specified prefix not present in the backup after restoration (default: false)
+	-conflict        Key conflict policy ("overwrite", "skip-existing", "newer-wins" or "cas") (default: "overwrite")
+	-dry-run         Report the changes a restore would make without writing anything
+	-atomic          Restore keys with the transaction api so partial writes are not possible
+	-txn-size        Maximum number of operations per transaction with atomic (default: 64)
+	-snapshot        Location for the pre-restore snapshot (default: "<file>.pre-restore" or "consul.bak.pre-restore" with stdin)
+	-no-snapshot     Do not write a pre-restore snapshot
 	-prefix          Path prefix for delete and restore operation
 	-addr            Optional consul address and port (default: "127.0.0.1:8500")
 	-scheme          Optional consul scheme ("http" or "https")
@@ -138,6 +233,10 @@ Options:
 	-client-key      Optional path to an unencrypted PEM encoded private key
 	-tls-skip-verify Optional bool for verifying a TLS certificate (not recommended)
 
+	The "cas" conflict policy creates missing keys and overwrites existing keys with
+	check-and-set writes against the keys read at the start of the restore.  Keys
+	created or modified by another client in between are skipped and reported.
+
 Please see documentation on GitHub for a detailed explanation of all options.
 https://github.com/myENA/consul-backinator
 
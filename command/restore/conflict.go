@@ -0,0 +1,142 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Key conflict policies
+const (
+	conflictOverwrite    = "overwrite"
+	conflictSkipExisting = "skip-existing"
+	conflictNewerWins    = "newer-wins"
+	conflictCAS          = "cas"
+)
+
+// Conflict policy errors
+var (
+	ErrConflictPolicy = errors.New("Conflict policy must be one of " +
+		"'overwrite', 'skip-existing', 'newer-wins' or 'cas'")
+	ErrConflictDelete = errors.New("The 'delete' option may only be " +
+		"used with the 'overwrite' conflict policy")
+)
+
+// checkConflictPolicy validates the passed conflict policy
+func checkConflictPolicy(policy string) error {
+	switch policy {
+	case conflictOverwrite, conflictSkipExisting, conflictNewerWins, conflictCAS:
+		return nil
+	default:
+		return ErrConflictPolicy
+	}
+}
+
+// conflict returns the reason a backed-up pair may not be written over
+// the live pair under the passed policy or an empty string when it may.
+// Modify indexes are only comparable when restoring to the cluster the
+// backup was taken from.  Pairs are never skipped by the check-and-set
+// policy here, it is enforced when writing against the live index instead.
+func conflict(policy string, kv, live *api.KVPair) string {
+	switch policy {
+	case conflictSkipExisting:
+		if live != nil {
+			return "key exists"
+		}
+	case conflictNewerWins:
+		if live != nil && live.ModifyIndex > kv.ModifyIndex {
+			return fmt.Sprintf("live index %d is newer than backup index %d",
+				live.ModifyIndex, kv.ModifyIndex)
+		}
+	}
+	return ""
+}
+
+// skipConflicts splits pairs into those that may be written over the
+// existing pairs under the configured policy and the keys that conflict.
+// Each conflict is reported unless quiet is set.  With the check-and-set
+// policy the returned pairs carry the live modify index, or zero for
+// missing keys, so writes fail when another writer changed the key after
+// the live keys were read.
+func (c *Command) skipConflicts(kvps, existing api.KVPairs, quiet bool) (api.KVPairs, []string) {
+	var live map[string]*api.KVPair // live pairs by key
+	var out api.KVPairs             // pairs to write
+	var skipped []string            // conflicting keys
+
+	// index live keys
+	live = make(map[string]*api.KVPair, len(existing))
+	for _, pair := range existing {
+		live[pair.Key] = pair
+	}
+
+	// check pairs
+	for _, pair := range kvps {
+		if reason := conflict(c.config.conflict, pair, live[pair.Key]); reason != "" {
+			if !quiet {
+				c.Log.Printf("[Conflict] Skipping key %s: %s", pair.Key, reason)
+			}
+			skipped = append(skipped, pair.Key)
+			continue
+		}
+		if c.config.conflict == conflictCAS {
+			var cas = *pair // pair written with the live index
+			cas.ModifyIndex = 0
+			if live[pair.Key] != nil {
+				cas.ModifyIndex = live[pair.Key].ModifyIndex
+			}
+			pair = &cas
+		}
+		out = append(out, pair)
+	}
+
+	// return writable pairs and conflicts
+	return out, skipped
+}
+
+// resolveConflicts removes pairs that conflict with live keys under
+// the configured policy and returns the pairs that may be written
+func (c *Command) resolveConflicts(kvps api.KVPairs, prefix string) (api.KVPairs, int, error) {
+	var existing api.KVPairs // live pairs
+	var skipped []string     // conflicting keys
+	var err error            // general error holder
+
+	// nothing to resolve when overwriting
+	if c.config.conflict == conflictOverwrite {
+		return kvps, 0, nil
+	}
+
+	// get live keys
+	if existing, _, err = c.consulClient.KV().List(prefix, nil); err != nil {
+		return nil, 0, err
+	}
+
+	// skip conflicting pairs
+	kvps, skipped = c.skipConflicts(kvps, existing, false)
+
+	// return writable pairs
+	return kvps, len(skipped), nil
+}
+
+// writeKey writes a single pair honoring the check-and-set policy
+func (c *Command) writeKey(pair *api.KVPair) error {
+	var ok bool   // cas result
+	var err error // general error holder
+
+	// write unconditionally unless using check-and-set
+	if c.config.conflict != conflictCAS {
+		_, err = c.consulClient.KV().Put(pair, nil)
+		return err
+	}
+
+	// write only if the index has not moved
+	if ok, _, err = c.consulClient.KV().CAS(pair, nil); err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("key created or modified during restore")
+	}
+
+	// all good
+	return nil
+}
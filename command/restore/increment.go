@@ -0,0 +1,89 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/kv"
+)
+
+// ErrIncrementBase is returned when increments are passed without a base bundle
+var ErrIncrementBase = errors.New("The 'increments' option requires restoring " +
+	"the 'kv' section of a base bundle")
+
+// readKeys reads backed-up keys and replays any requested increments
+func (c *Command) readKeys() (api.KVPairs, error) {
+	var kvps api.KVPairs // decoded kv pairs
+	var data []byte      // read json data
+	var err error        // general error holder
+
+	// read json data from source
+	if data, err = c.readSection(common.SectionKV, c.config.fileName); err != nil {
+		return nil, err
+	}
+
+	// decode data
+	if kvps, err = kv.Decode(data); err != nil {
+		return nil, err
+	}
+
+	// replay increments if requested
+	if c.config.increments != "" {
+		return c.applyIncrements(kvps)
+	}
+
+	// return pairs
+	return kvps, nil
+}
+
+// applyIncrements replays the requested increments in order on top of
+// the base pairs.  Each increment must have been taken against the index
+// of the bundle before it in the chain.
+func (c *Command) applyIncrements(kvps api.KVPairs) (api.KVPairs, error) {
+	var index uint64 // index of the previous bundle in the chain
+	var err error    // general error holder
+
+	// increments are only comparable with a bundle index
+	if c.bundle == nil {
+		return nil, ErrIncrementBase
+	}
+	index = c.bundle.Manifest.Index
+
+	// loop through increments
+	for _, fname := range strings.Split(c.config.increments, ",") {
+		var bundle *common.Bundle // increment bundle
+		var inc *kv.Increment     // decoded increment
+		var data []byte           // kv section data
+
+		// read increment
+		if bundle, err = common.ReadBundle(fname, "", c.config.keys); err != nil {
+			return nil, fmt.Errorf("increment %s: %s", fname, err.Error())
+		}
+		if data, err = bundle.Section(common.SectionKV); err != nil {
+			return nil, fmt.Errorf("increment %s: %s", fname, err.Error())
+		}
+		if inc, err = kv.DecodeIncrement(data); err != nil {
+			return nil, fmt.Errorf("increment %s: %s", fname, err.Error())
+		}
+
+		// check chain
+		if inc.Since != index {
+			return nil, fmt.Errorf("increment %s was taken since index %d "+
+				"but the previous bundle was taken at index %d", fname, inc.Since, index)
+		}
+		index = bundle.Manifest.Index
+
+		// replay changes
+		kvps = inc.Apply(kvps)
+
+		// show details
+		c.Log.Printf("[Info] Applied increment %s with %d changed and %d deleted keys",
+			fname, len(inc.Pairs), len(inc.Deleted))
+	}
+
+	// return pairs
+	return kvps, nil
+}
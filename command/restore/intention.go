@@ -0,0 +1,100 @@
+package restore
+
+import (
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/intention"
+)
+
+// restoreIntentions reads intentions from a backup file and restores them to consul.
+// Intention identifiers differ between clusters so existing intentions are
+// matched by source and destination name.
+func (c *Command) restoreIntentions() (int, error) {
+	var snap *intention.Snapshot // intention snapshot
+	var restored map[string]bool // destinations restored from config entries
+	var count int                // intention count
+	var data []byte              // read json data
+	var err error                // general error holder
+
+	// read json data from source
+	if data, err = c.readSection(common.SectionIntentions, c.config.intentionFileName); err != nil {
+		return 0, err
+	}
+
+	// decode data
+	if snap, err = intention.Decode(data); err != nil {
+		return 0, err
+	}
+
+	// init restored destinations
+	restored = make(map[string]bool)
+
+	// config entries are written whole per destination and
+	// include permissions not supported by the legacy endpoints
+	for _, entry := range snap.ConfigEntries {
+		// write entry
+		if _, _, err = c.consulClient.ConfigEntries().Set(entry, nil); err != nil {
+			c.Log.Printf("[Warning] Failed to restore intentions for %s: %s",
+				intention.Name(entry.Namespace, entry.Name), err.Error())
+			continue
+		}
+		// success - record destination and increment count
+		restored[intention.Name(entry.Namespace, entry.Name)] = true
+		count += len(entry.Sources)
+	}
+
+	// loop through remaining intentions
+	for _, ixn := range snap.Intentions {
+		// skip destinations already restored
+		if restored[intention.Name(ixn.DestinationNS, ixn.DestinationName)] {
+			continue
+		}
+		// write intention
+		if err = c.restoreIntention(ixn); err != nil {
+			c.Log.Printf("[Warning] Failed to restore intention %s: %s",
+				ixn.String(), err.Error())
+		} else {
+			// success - increment count
+			count++
+		}
+	}
+
+	// return intention count - no error
+	return count, nil
+}
+
+// restoreIntention writes an intention matched by source and destination name.
+// The exact upsert endpoint is only available on consul 1.9 and later so older
+// clusters fall back to updating an existing intention or creating a new one.
+func (c *Command) restoreIntention(ixn *api.Intention) error {
+	var existing *api.Intention // existing intention
+	var err error               // general error holder
+
+	// remove id from backed-up intention
+	ixn.ID = ""
+
+	// attempt upsert
+	if _, err = c.consulClient.Connect().IntentionUpsert(ixn, nil); err == nil {
+		return nil
+	}
+
+	// check for existing intention
+	if existing, _, err = c.consulClient.Connect().IntentionGetExact(
+		intention.Name(ixn.SourceNS, ixn.SourceName),
+		intention.Name(ixn.DestinationNS, ixn.DestinationName),
+		nil); err != nil {
+		return err
+	}
+
+	if existing != nil {
+		// update existing intention
+		ixn.ID = existing.ID
+		_, err = c.consulClient.Connect().IntentionUpdate(ixn, nil)
+	} else {
+		// create missing intention
+		_, _, err = c.consulClient.Connect().IntentionCreate(ixn, nil)
+	}
+
+	// return write error
+	return err
+}
@@ -0,0 +1,431 @@
+package restore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/acl"
+	"github.com/myENA/consul-backinator/common/configentry"
+	"github.com/myENA/consul-backinator/common/intention"
+	"github.com/myENA/consul-backinator/common/kv"
+)
+
+// plan describes the changes a restore of a single section would make
+type plan struct {
+	section     string
+	deleted     []string
+	created     []string
+	overwritten []string
+	skipped     []string
+	unchanged   int
+}
+
+// print writes the planned changes to stdout
+func (p *plan) print() {
+	for _, name := range p.deleted {
+		fmt.Printf("[%s] delete %s\n", p.section, name)
+	}
+	for _, name := range p.created {
+		fmt.Printf("[%s] create %s\n", p.section, name)
+	}
+	for _, name := range p.overwritten {
+		fmt.Printf("[%s] overwrite %s\n", p.section, name)
+	}
+	for _, name := range p.skipped {
+		fmt.Printf("[%s] skip %s\n", p.section, name)
+	}
+	fmt.Printf("[%s] %d to delete, %d to create, %d to overwrite, %d to skip, %d unchanged\n",
+		p.section, len(p.deleted), len(p.created), len(p.overwritten), len(p.skipped), p.unchanged)
+}
+
+// planRestore reads, validates and transforms all requested sections
+// and prints the changes a restore would make without writing anything
+func (c *Command) planRestore() error {
+	var p *plan   // section plan
+	var err error // general error holder
+
+	// plan keys unless otherwise requested
+	if !c.config.noKV {
+		if p, err = c.planKeys(); err != nil {
+			return fmt.Errorf("kv data: %s", err.Error())
+		}
+		p.print()
+	}
+
+	// plan acls if requested
+	if c.config.aclFileName != "" {
+		if p, err = c.planACLs(); err != nil {
+			return fmt.Errorf("ACL data: %s", err.Error())
+		}
+		p.print()
+	}
+
+	// plan queries if requested
+	if c.config.queryFileName != "" {
+		if p, err = c.planQueries(); err != nil {
+			return fmt.Errorf("query definitions: %s", err.Error())
+		}
+		p.print()
+	}
+
+	// plan config entries if requested
+	if c.config.configFileName != "" {
+		if p, err = c.planConfigEntries(); err != nil {
+			return fmt.Errorf("config entries: %s", err.Error())
+		}
+		p.print()
+	}
+
+	// plan intentions if requested
+	if c.config.intentionFileName != "" {
+		if p, err = c.planIntentions(); err != nil {
+			return fmt.Errorf("intentions: %s", err.Error())
+		}
+		p.print()
+	}
+
+	// all good
+	return nil
+}
+
+// planKeys compares backed-up keys with the keys under the restore prefix
+func (c *Command) planKeys() (*plan, error) {
+	var kvps, existing api.KVPairs // backed-up and existing pairs
+	var changes *kv.Changes        // key changes
+	var absent []string            // existing keys absent from the backup
+	var skipped []string           // conflicting keys
+	var err error                  // general error holder
+
+	// read keys
+	if kvps, err = c.readKeys(); err != nil {
+		return nil, err
+	}
+
+	// transform paths and filter by prefix
+	c.pathTransformer.Transform(kvps)
+	kvps = kv.Filter(kvps, c.config.consulPrefix)
+
+	// get existing keys
+	if existing, _, err = c.consulClient.KV().List(c.config.consulPrefix, nil); err != nil {
+		return nil, err
+	}
+
+	// find keys absent from the backup before skipping conflicts as a mirror does
+	absent = kv.Compare(existing, kvps).Removed
+
+	// set aside keys the conflict policy would skip
+	kvps, skipped = c.skipConflicts(kvps, existing, true)
+
+	// compare keys
+	changes = kv.Compare(existing, kvps)
+
+	// build plan - existing keys are only removed by a delete or mirror.
+	// A delete clears the whole tree including skipped keys while a mirror
+	// keeps every key present in the backup.
+	p := &plan{
+		section:   common.SectionKV,
+		created:   changes.Added,
+		skipped:   skipped,
+		unchanged: len(kvps) - len(changes.Added) - len(changes.Modified),
+	}
+	if c.config.delTree {
+		p.deleted = changes.Removed
+	} else if c.config.mirror {
+		p.deleted = absent
+	}
+	for _, change := range changes.Modified {
+		p.overwritten = append(p.overwritten, change.Key)
+	}
+
+	// return plan
+	return p, nil
+}
+
+// planACLs matches backed-up acl objects with existing objects
+// in the same way they are matched during a restore
+func (c *Command) planACLs() (*plan, error) {
+	var snap *acl.Snapshot     // acl snapshot
+	var legacy []*api.ACLEntry // legacy acl tokens
+	var data []byte            // read json data
+	var err error              // general error holder
+
+	// read json data from source
+	if data, err = c.readSection(common.SectionACLs, c.config.aclFileName); err != nil {
+		return nil, err
+	}
+
+	// decode data
+	if snap, legacy, err = acl.Decode(data); err != nil {
+		return nil, err
+	}
+
+	// init plan
+	p := &plan{section: common.SectionACLs}
+
+	// legacy tokens are always created
+	if snap == nil {
+		for _, entry := range legacy {
+			p.created = append(p.created, "token "+entry.Name)
+		}
+		return p, nil
+	}
+
+	// plan policies matched by name
+	policies, _, err := c.consulClient.ACL().PolicyList(nil)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(policies))
+	for _, entry := range policies {
+		names[entry.Name] = true
+	}
+	for _, policy := range snap.Policies {
+		switch {
+		case policy.ID == acl.GlobalManagementPolicyID:
+			p.skipped = append(p.skipped, "policy "+policy.Name)
+		case names[policy.Name]:
+			p.overwritten = append(p.overwritten, "policy "+policy.Name)
+		default:
+			p.created = append(p.created, "policy "+policy.Name)
+		}
+	}
+
+	// plan roles matched by name
+	roles, _, err := c.consulClient.ACL().RoleList(nil)
+	if err != nil {
+		return nil, err
+	}
+	names = make(map[string]bool, len(roles))
+	for _, entry := range roles {
+		names[entry.Name] = true
+	}
+	for _, role := range snap.Roles {
+		p.add(names[role.Name], "role "+role.Name)
+	}
+
+	// plan auth methods matched by name
+	methods, _, err := c.consulClient.ACL().AuthMethodList(nil)
+	if err != nil {
+		return nil, err
+	}
+	names = make(map[string]bool, len(methods))
+	for _, entry := range methods {
+		names[entry.Name] = true
+	}
+	for _, method := range snap.AuthMethods {
+		p.add(names[method.Name], "auth method "+method.Name)
+	}
+
+	// plan binding rules matched by selector, bind type and bind name
+	for _, rule := range snap.BindingRules {
+		var found bool // match check
+		existing, _, err := c.consulClient.ACL().BindingRuleList(rule.AuthMethod, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, er := range existing {
+			if er.Selector == rule.Selector && er.BindType == rule.BindType &&
+				er.BindName == rule.BindName {
+				found = true
+				break
+			}
+		}
+		p.add(found, fmt.Sprintf("binding rule %s (%s)", rule.AuthMethod, rule.BindName))
+	}
+
+	// plan tokens matched by accessor
+	tokens, _, err := c.consulClient.ACL().TokenList(nil)
+	if err != nil {
+		return nil, err
+	}
+	names = make(map[string]bool, len(tokens))
+	for _, entry := range tokens {
+		names[entry.AccessorID] = true
+	}
+	for _, token := range snap.Tokens {
+		switch {
+		case token.AuthMethod != "":
+			p.skipped = append(p.skipped, "token "+token.AccessorID+" (auth method)")
+		case token.ExpirationTime != nil && token.ExpirationTime.Before(time.Now()):
+			p.skipped = append(p.skipped, "token "+token.AccessorID+" (expired)")
+		default:
+			p.add(names[token.AccessorID], "token "+token.AccessorID)
+		}
+	}
+
+	// return plan
+	return p, nil
+}
+
+// planQueries matches backed-up query definitions with existing queries by id
+func (c *Command) planQueries() (*plan, error) {
+	var queries []*api.PreparedQueryDefinition // query definitions
+	var data []byte                            // read json data
+	var err error                              // general error holder
+
+	// read json data from source
+	if data, err = c.readSection(common.SectionQueries, c.config.queryFileName); err != nil {
+		return nil, err
+	}
+
+	// decode data
+	if err = json.Unmarshal(data, &queries); err != nil {
+		return nil, err
+	}
+
+	// init plan
+	p := &plan{section: common.SectionQueries}
+
+	// get existing queries
+	existing, err := c.existingQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	// loop through queries
+	for _, query := range queries {
+		p.add(existing[query.ID], fmt.Sprintf("query %s (%s)", query.Name, query.ID))
+	}
+
+	// return plan
+	return p, nil
+}
+
+// planConfigEntries matches backed-up config entries with existing entries by kind and name
+func (c *Command) planConfigEntries() (*plan, error) {
+	var entries []api.ConfigEntry // config entries
+	var data []byte               // read json data
+	var err error                 // general error holder
+
+	// read json data from source
+	if data, err = c.readSection(common.SectionConfigs, c.config.configFileName); err != nil {
+		return nil, err
+	}
+
+	// decode data
+	if entries, err = configentry.Decode(data); err != nil {
+		return nil, err
+	}
+
+	// init plan
+	p := &plan{section: common.SectionConfigs}
+
+	// loop through entries in restore order
+	configentry.Sort(entries)
+	for _, entry := range entries {
+		var exists bool // existing entry
+		if exists, err = c.configEntryExists(entry.GetKind(), entry.GetName()); err != nil {
+			return nil, err
+		}
+		p.add(exists, entry.GetKind()+"/"+entry.GetName())
+	}
+
+	// return plan
+	return p, nil
+}
+
+// planIntentions matches backed-up intentions with existing intentions
+// by source and destination name
+func (c *Command) planIntentions() (*plan, error) {
+	var snap *intention.Snapshot // intention snapshot
+	var restored map[string]bool // destinations restored from config entries
+	var data []byte              // read json data
+	var err error                // general error holder
+
+	// read json data from source
+	if data, err = c.readSection(common.SectionIntentions, c.config.intentionFileName); err != nil {
+		return nil, err
+	}
+
+	// decode data
+	if snap, err = intention.Decode(data); err != nil {
+		return nil, err
+	}
+
+	// init plan
+	p := &plan{section: common.SectionIntentions}
+	restored = make(map[string]bool)
+
+	// config entries are written whole per destination
+	for _, entry := range snap.ConfigEntries {
+		var exists bool // existing entry
+		name := intention.Name(entry.Namespace, entry.Name)
+		if exists, err = c.configEntryExists(api.ServiceIntentions, entry.Name); err != nil {
+			return nil, err
+		}
+		p.add(exists, "destination "+name)
+		restored[name] = true
+	}
+
+	// loop through remaining intentions
+	for _, ixn := range snap.Intentions {
+		// skip destinations already planned
+		if restored[intention.Name(ixn.DestinationNS, ixn.DestinationName)] {
+			continue
+		}
+		// missing intentions are returned as nil without an error
+		existing, _, err := c.consulClient.Connect().IntentionGetExact(
+			intention.Name(ixn.SourceNS, ixn.SourceName),
+			intention.Name(ixn.DestinationNS, ixn.DestinationName),
+			nil)
+		if err != nil {
+			return nil, err
+		}
+		p.add(existing != nil, ixn.String())
+	}
+
+	// return plan
+	return p, nil
+}
+
+// add records an object as overwritten when it exists or created otherwise
+func (p *plan) add(exists bool, name string) {
+	if exists {
+		p.overwritten = append(p.overwritten, name)
+		return
+	}
+	p.created = append(p.created, name)
+}
+
+// existingQueries returns the ids of all existing query definitions.  Queries
+// are listed since reading a missing query fails to decode the response.
+func (c *Command) existingQueries() (map[string]bool, error) {
+	var queries []*api.PreparedQueryDefinition // existing queries
+	var ids map[string]bool                    // existing ids
+	var err error                              // general error holder
+
+	// list queries
+	if queries, _, err = c.consulClient.PreparedQuery().List(nil); err != nil {
+		return nil, err
+	}
+
+	// index ids
+	ids = make(map[string]bool, len(queries))
+	for _, query := range queries {
+		ids[query.ID] = true
+	}
+
+	// return ids
+	return ids, nil
+}
+
+// configEntryExists checks if a config entry with the same kind and name exists
+func (c *Command) configEntryExists(kind, name string) (bool, error) {
+	existing, _, err := c.consulClient.ConfigEntries().Get(kind, name, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return existing != nil, nil
+}
+
+// isNotFound checks if an api error was caused by a missing object
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "Unexpected response code: 404")
+}
@@ -2,26 +2,23 @@ package restore
 
 import (
 	"encoding/json"
-	"strings"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/configentry"
+	"github.com/myENA/consul-backinator/common/kv"
 )
 
 // restoreKeys reads keys from a backup file and restores them to consul
 func (c *Command) restoreKeys() (int, error) {
-	var kvps api.KVPairs // decoded kv pairs
-	var count int        // key count
-	var data []byte      // read json data
-	var err error        // general error holder
-
-	// read json data from source
-	if data, err = common.ReadData(c.config.fileName, c.config.cryptKey); err != nil {
-		return 0, err
-	}
-
-	// decode data
-	if err = json.Unmarshal(data, &kvps); err != nil {
+	var kvps api.KVPairs   // decoded kv pairs
+	var deletes api.TxnOps // keys absent from the backup
+	var count int          // key count
+	var skipped int        // conflicting key count
+	var err error          // general error holder
+
+	// read keys
+	if kvps, err = c.readKeys(); err != nil {
 		return 0, err
 	}
 
@@ -35,6 +32,30 @@ func (c *Command) restoreKeys() (int, error) {
 		myPrefix = "" // special case for root
 	}
 
+	// filter by prefix
+	kvps = kv.Filter(kvps, myPrefix)
+
+	// find keys absent from the backup if mirroring
+	if c.config.mirror {
+		if deletes, err = c.deleteOps(kvps, myPrefix); err != nil {
+			return 0, err
+		}
+	}
+
+	// skip conflicting keys
+	if kvps, skipped, err = c.resolveConflicts(kvps, myPrefix); err != nil {
+		return 0, err
+	}
+	if skipped > 0 {
+		c.Log.Printf("[Warning] Skipped %d conflicting keys with the %s conflict policy",
+			skipped, c.config.conflict)
+	}
+
+	// restore using transactions if requested
+	if c.config.atomic {
+		return c.restoreKeysAtomic(kvps, deletes, myPrefix)
+	}
+
 	// delete tree before restore if requested
 	if c.config.delTree {
 		// send the delete request
@@ -44,67 +65,56 @@ func (c *Command) restoreKeys() (int, error) {
 	}
 
 	// loop through keys
-	for _, kv := range kvps {
-		// filter by prefix
-		if myPrefix != "" && !strings.HasPrefix(kv.Key, myPrefix) {
-			continue
-		}
+	for _, pair := range kvps {
 		// write key
-		if _, err = c.consulClient.KV().Put(kv, nil); err != nil {
+		if err = c.writeKey(pair); err != nil {
 			c.Log.Printf("[Warning] Failed to restore key %s: %s",
-				kv.Key, err.Error())
+				pair.Key, err.Error())
 		} else {
 			// success - increment count
 			count++
 		}
 	}
 
+	// remove keys absent from the backup after writing so the tree is never empty
+	if len(deletes) > 0 {
+		c.mirrorDelete(deletes)
+	}
+
 	// return key count - no error
 	return count, nil
 }
 
-// restoreACLs reads acl tokens from a backup file and restores them to consul
-func (c *Command) restoreACLs() (int, error) {
-	var acls []*api.ACLEntry // acl tokens
-	var count int            // token count
-	var data []byte          // read json data
-	var err error            // general error holder
+// mirrorDelete removes keys that are not present in the backup
+func (c *Command) mirrorDelete(deletes api.TxnOps) {
+	var count int // deleted key count
 
-	// read json data from source
-	if data, err = common.ReadData(c.config.aclFileName, c.config.cryptKey); err != nil {
-		return 0, err
-	}
-
-	// decode data
-	if err = json.Unmarshal(data, &acls); err != nil {
-		return 0, err
-	}
-
-	// loop through acls
-	for _, acl := range acls {
-		// write token
-		if _, _, err = c.consulClient.ACL().Create(acl, nil); err != nil {
-			c.Log.Printf("[Warning] Failed to restore ACL token %s: %s",
-				acl.Name, err.Error())
+	// loop through keys
+	for _, op := range deletes {
+		// delete key
+		if _, err := c.consulClient.KV().Delete(op.KV.Key, nil); err != nil {
+			c.Log.Printf("[Warning] Failed to delete key %s: %s",
+				op.KV.Key, err.Error())
 		} else {
 			// success - increment count
 			count++
 		}
 	}
 
-	// return acl count - no error
-	return count, nil
+	// show result
+	c.Log.Printf("[Info] Deleted %d keys not present in %s", count, c.config.fileName)
 }
 
 // restoreQueries reads query definitions from a backup file and restores them to consul
 func (c *Command) restoreQueries() (int, error) {
 	var queries []*api.PreparedQueryDefinition // query definitions
+	var existing map[string]bool               // existing query ids
 	var count int                              // query count
 	var data []byte                            // read json data
 	var err error                              // general error holder
 
 	// read json data from source
-	if data, err = common.ReadData(c.config.queryFileName, c.config.cryptKey); err != nil {
+	if data, err = c.readSection(common.SectionQueries, c.config.queryFileName); err != nil {
 		return 0, err
 	}
 
@@ -113,11 +123,15 @@ func (c *Command) restoreQueries() (int, error) {
 		return 0, err
 	}
 
+	// get existing queries
+	if existing, err = c.existingQueries(); err != nil {
+		return 0, err
+	}
+
 	// loop through queries
 	for _, query := range queries {
-		var existing []*api.PreparedQueryDefinition // existing query definitions
 		// check for existing query
-		if existing, _, err = c.consulClient.PreparedQuery().Get(query.ID, nil); err != nil && existing != nil {
+		if existing[query.ID] {
 			// update existing query
 			if _, err = c.consulClient.PreparedQuery().Update(query, nil); err != nil {
 				c.Log.Printf("[Warning] Failed to update existing query definition %s: %s",
@@ -143,3 +157,39 @@ func (c *Command) restoreQueries() (int, error) {
 	// return query count - no error
 	return count, nil
 }
+
+// restoreConfigEntries reads config entries from a backup file and restores them to consul
+func (c *Command) restoreConfigEntries() (int, error) {
+	var entries []api.ConfigEntry // config entries
+	var count int                 // entry count
+	var data []byte               // read json data
+	var err error                 // general error holder
+
+	// read json data from source
+	if data, err = c.readSection(common.SectionConfigs, c.config.configFileName); err != nil {
+		return 0, err
+	}
+
+	// decode data
+	if entries, err = configentry.Decode(data); err != nil {
+		return 0, err
+	}
+
+	// order entries so dependencies are written first
+	configentry.Sort(entries)
+
+	// loop through entries
+	for _, entry := range entries {
+		// write entry
+		if _, _, err = c.consulClient.ConfigEntries().Set(entry, nil); err != nil {
+			c.Log.Printf("[Warning] Failed to restore config entry %s/%s: %s",
+				entry.GetKind(), entry.GetName(), err.Error())
+		} else {
+			// success - increment count
+			count++
+		}
+	}
+
+	// return entry count - no error
+	return count, nil
+}
@@ -0,0 +1,74 @@
+package restore
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/myENA/consul-backinator/command/backup"
+	"github.com/myENA/consul-backinator/common"
+	cc "github.com/myENA/consul-backinator/common/config"
+)
+
+// snapshotSuffix is appended to the source file name to build
+// the default pre-restore snapshot location
+const snapshotSuffix = ".pre-restore"
+
+// ErrSnapshotFailed is returned when the pre-restore snapshot could not be written
+var ErrSnapshotFailed = errors.New("Failed to write pre-restore snapshot.  " +
+	"Pass 'no-snapshot' to restore without a snapshot.")
+
+// writeSnapshot backs up everything the restore may change to a single bundle
+// using the backup command.  Only keys under the restore prefix are included.
+// The snapshot is encrypted to the public keys of any passed identities so
+// live secrets are not protected by the passphrase alone.
+func (c *Command) writeSnapshot() error {
+	var sections []string   // snapshot sections
+	var args []string       // backup arguments
+	var recipients []string // identity public keys
+	var err error           // general error holder
+
+	// include sections that will be restored
+	if !c.config.noKV {
+		sections = append(sections, common.SectionKV)
+	}
+	if c.config.aclFileName != "" {
+		sections = append(sections, common.SectionACLs)
+	}
+	if c.config.queryFileName != "" {
+		sections = append(sections, common.SectionQueries)
+	}
+	if c.config.configFileName != "" {
+		sections = append(sections, common.SectionConfigs)
+	}
+	if c.config.intentionFileName != "" {
+		sections = append(sections, common.SectionIntentions)
+	}
+
+	// build backup arguments
+	args = []string{
+		"-file", c.config.snapshot,
+		"-key", c.config.cryptKey,
+		"-bundle", strings.Join(sections, ","),
+		"-prefix", c.config.consulPrefix,
+	}
+	if recipients, err = c.config.keys.IdentityRecipients(); err != nil {
+		return err
+	}
+	if len(recipients) > 0 {
+		args = append(args, "-recipients", strings.Join(recipients, ","))
+	}
+	args = append(args, cc.SharedConsulArgs(c.config.consulConfig)...)
+
+	// run backup - an empty cluster is a valid snapshot
+	if (&backup.Command{
+		Self:       c.Self,
+		Version:    c.Version,
+		AllowEmpty: true,
+		Log:        c.Log,
+	}).Run(args) != 0 {
+		return ErrSnapshotFailed
+	}
+
+	// all good
+	return nil
+}
@@ -0,0 +1,195 @@
+package restore
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Consul rejects transactions with more than 64 operations or a request
+// body larger than 512KB.  Batches are sized by the encoded request size
+// of each operation, which is the base64 encoded value, the key and the
+// JSON field names, verb, flags, index and session of the operation, and
+// kept below the body limit with room left for the surrounding array.
+const (
+	maxTxnOps     = 64
+	maxTxnBytes   = 500 * 1024
+	txnOpOverhead = 256
+)
+
+// ErrTxnSize is returned when the requested transaction size is out of range
+var ErrTxnSize = fmt.Errorf("Transaction size must be between 1 and %d operations", maxTxnOps)
+
+// restoreKeysAtomic writes keys and applies the passed mirror deletes using
+// the transaction api.  When everything fits in a single transaction the
+// delete and all writes succeed or fail together.  Larger restores are split
+// into batches that are each atomic and the failed batch is reported along
+// with the batches already applied.
+func (c *Command) restoreKeysAtomic(kvps api.KVPairs, mirror api.TxnOps, prefix string) (int, error) {
+	var sets, deletes api.TxnOps // write and delete operations
+	var batches []api.TxnOps     // operation batches
+	var count int                // key count
+	var err error                // general error holder
+
+	// build write operations
+	for _, kv := range kvps {
+		op := &api.KVTxnOp{
+			Verb:  api.KVSet,
+			Key:   kv.Key,
+			Value: kv.Value,
+			Flags: kv.Flags,
+		}
+		// write only if the index has not moved when using check-and-set
+		if c.config.conflict == conflictCAS {
+			op.Verb = api.KVCAS
+			op.Index = kv.ModifyIndex
+		}
+		sets = append(sets, &api.TxnOp{KV: op})
+	}
+
+	// split operations - mirror deletes follow the writes
+	batches = splitOps(append(sets, mirror...), c.config.txnSize)
+
+	// include the delete if requested
+	if c.config.delTree {
+		// a single transaction can replace the tree in one step
+		batches = splitOps(append(api.TxnOps{{KV: &api.KVTxnOp{
+			Verb: api.KVDeleteTree,
+			Key:  prefix,
+		}}}, sets...), c.config.txnSize)
+
+		// otherwise delete only the keys that would not be overwritten
+		if len(batches) > 1 {
+			if deletes, err = c.deleteOps(kvps, prefix); err != nil {
+				return 0, err
+			}
+			batches = splitOps(append(deletes, sets...), c.config.txnSize)
+		}
+	}
+
+	// warn when the restore can not be applied at once
+	if len(batches) > 1 {
+		c.Log.Printf("[Warning] Restore requires %d transactions and is only "+
+			"atomic within each transaction", len(batches))
+	}
+
+	// apply batches in order
+	for i, batch := range batches {
+		if err = c.applyTxn(batch); err != nil {
+			return count, fmt.Errorf("transaction %d of %d failed with %d keys "+
+				"written by earlier transactions: %s", i+1, len(batches), count, err.Error())
+		}
+		// success - count written keys
+		count += len(setOps(batch))
+	}
+
+	// return key count - no error
+	return count, nil
+}
+
+// deleteOps returns delete operations for existing keys
+// under the prefix that are not present in the backup
+func (c *Command) deleteOps(kvps api.KVPairs, prefix string) (api.TxnOps, error) {
+	var keys []string            // existing keys
+	var restored map[string]bool // restored keys
+	var ops api.TxnOps           // delete operations
+	var err error                // general error holder
+
+	// get existing keys
+	if keys, _, err = c.consulClient.KV().Keys(prefix, "", nil); err != nil {
+		return nil, err
+	}
+
+	// index restored keys
+	restored = make(map[string]bool, len(kvps))
+	for _, kv := range kvps {
+		restored[kv.Key] = true
+	}
+
+	// delete keys that would not be overwritten
+	for _, key := range keys {
+		if !restored[key] {
+			ops = append(ops, &api.TxnOp{KV: &api.KVTxnOp{
+				Verb: api.KVDelete,
+				Key:  key,
+			}})
+		}
+	}
+
+	// return operations
+	return ops, nil
+}
+
+// applyTxn applies a single transaction and returns
+// the operation errors reported by consul
+func (c *Command) applyTxn(ops api.TxnOps) error {
+	var ok bool               // transaction result
+	var resp *api.TxnResponse // transaction response
+	var msgs []string         // error messages
+	var err error             // general error holder
+
+	// send transaction
+	if ok, resp, _, err = c.consulClient.Txn().Txn(ops, nil); err != nil {
+		return err
+	}
+
+	// check result
+	if ok {
+		return nil
+	}
+
+	// collect errors
+	for _, e := range resp.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s (%s)", e.What, ops[e.OpIndex].KV.Key))
+	}
+
+	// return errors
+	return errors.New(strings.Join(msgs, ", "))
+}
+
+// splitOps splits operations into batches limited by
+// operation count and total encoded request size
+func splitOps(ops api.TxnOps, size int) []api.TxnOps {
+	var batches []api.TxnOps // output batches
+	var batch api.TxnOps     // current batch
+	var bytes int            // current batch encoded size
+
+	// loop through operations
+	for _, op := range ops {
+		var opBytes = encodedSize(op) // operation encoded size
+		// start a new batch when full
+		if len(batch) > 0 && (len(batch) >= size || bytes+opBytes > maxTxnBytes) {
+			batches = append(batches, batch)
+			batch, bytes = nil, 0
+		}
+		batch = append(batch, op)
+		bytes += opBytes
+	}
+
+	// add last batch
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	// return batches
+	return batches
+}
+
+// encodedSize returns the approximate size of an operation in the request body
+func encodedSize(op *api.TxnOp) int {
+	return base64.StdEncoding.EncodedLen(len(op.KV.Value)) + len(op.KV.Key) + txnOpOverhead
+}
+
+// setOps returns only the write operations
+func setOps(ops api.TxnOps) api.TxnOps {
+	var out api.TxnOps // write operations
+	for _, op := range ops {
+		if op.KV.Verb == api.KVSet || op.KV.Verb == api.KVCAS {
+			out = append(out, op)
+		}
+	}
+	return out
+}
@@ -1,15 +1,27 @@
 package restore
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
 
+	"github.com/myENA/consul-backinator/common"
 	cc "github.com/myENA/consul-backinator/common/config"
 	ccns "github.com/myENA/consul-backinator/common/consul"
 )
 
+// Exported option errors
+var (
+	ErrBundleFiles = errors.New("Separate 'acls', 'queries', 'configs' or 'intentions' " +
+		"files can not be combined with the 'bundle' option")
+	ErrMirrorDelete = errors.New("The 'mirror' and 'delete' options may not be used together")
+)
+
+// defaultFileName is the default source location
+const defaultFileName = "consul.bak"
+
 // setupFlags initializes the instance configuration
 func (c *Command) setupFlags(args []string) error {
 	var cmdFlags *flag.FlagSet // instance flagset
@@ -29,20 +41,48 @@ func (c *Command) setupFlags(args []string) error {
 	cmdFlags.Usage = func() { fmt.Fprint(os.Stdout, c.Help()); os.Exit(0) }
 
 	// declare flags
-	cmdFlags.StringVar(&c.config.fileName, "file", "consul.bak",
+	cmdFlags.StringVar(&c.config.fileName, "file", defaultFileName,
 		"Source")
+	cmdFlags.StringVar(&c.config.sigName, "sig", "",
+		"Optional signature location")
 	cmdFlags.StringVar(&c.config.cryptKey, "key", "password",
 		"Passphrase for data encryption and signature validation")
+	cmdFlags.StringVar(&c.config.identity, "identity", "",
+		"Optional identity file for public key encrypted data")
+	cmdFlags.StringVar(&c.config.verifyKey, "verify-key", "",
+		"Optional public key file used to validate signed data")
 	cmdFlags.BoolVar(&c.config.noKV, "nokv", false,
 		"Do not attempt to restore kv data")
 	cmdFlags.StringVar(&c.config.aclFileName, "acls", "",
-		"Optional source filename for acl tokens")
+		"Optional source filename for acl data")
 	cmdFlags.StringVar(&c.config.queryFileName, "queries", "",
 		"Optional source filename for query definitions")
+	cmdFlags.StringVar(&c.config.configFileName, "configs", "",
+		"Optional source filename for config entries")
+	cmdFlags.StringVar(&c.config.intentionFileName, "intentions", "",
+		"Optional source filename for intentions")
+	cmdFlags.StringVar(&c.config.bundle, "bundle", "",
+		"Optional list of sections to restore from a bundle file")
 	cmdFlags.StringVar(&c.config.pathTransform, "transform", "",
 		"Optional path transformation")
+	cmdFlags.StringVar(&c.config.increments, "increments", "",
+		"Optional list of incremental bundles to replay")
 	cmdFlags.BoolVar(&c.config.delTree, "delete", false,
 		"Delete all keys under specified prefix")
+	cmdFlags.BoolVar(&c.config.mirror, "mirror", false,
+		"Delete only keys under specified prefix not present in the backup")
+	cmdFlags.StringVar(&c.config.conflict, "conflict", conflictOverwrite,
+		"Key conflict policy")
+	cmdFlags.BoolVar(&c.config.dryRun, "dry-run", false,
+		"Report planned changes without writing anything")
+	cmdFlags.BoolVar(&c.config.atomic, "atomic", false,
+		"Restore keys using transactions")
+	cmdFlags.IntVar(&c.config.txnSize, "txn-size", maxTxnOps,
+		"Maximum number of operations per transaction")
+	cmdFlags.StringVar(&c.config.snapshot, "snapshot", "",
+		"Location for the pre-restore snapshot")
+	cmdFlags.BoolVar(&c.config.noSnapshot, "no-snapshot", false,
+		"Do not write a pre-restore snapshot")
 	cmdFlags.StringVar(&c.config.consulPrefix, "prefix", "/",
 		"Prefix for delete operation")
 
@@ -59,6 +99,62 @@ func (c *Command) setupFlags(args []string) error {
 		return cc.ErrUnknownArg
 	}
 
+	// only the source may be streamed
+	if err := cc.CheckStdio(c.config.sigName, c.config.aclFileName, c.config.queryFileName,
+		c.config.configFileName, c.config.intentionFileName, c.config.increments, c.config.snapshot); err != nil {
+		return err
+	}
+
+	// default snapshot location
+	if c.config.snapshot == "" {
+		c.config.snapshot = common.AppendSuffix(c.config.fileName, snapshotSuffix)
+		if common.IsStdio(c.config.fileName) {
+			c.config.snapshot = defaultFileName + snapshotSuffix
+		}
+	}
+
+	// increments are replayed on top of a bundle
+	if c.config.increments != "" && c.config.bundle == "" {
+		return ErrIncrementBase
+	}
+
+	// mirror replaces delete
+	if c.config.mirror && c.config.delTree {
+		return ErrMirrorDelete
+	}
+
+	// check conflict policy
+	if err := checkConflictPolicy(c.config.conflict); err != nil {
+		return err
+	}
+	if c.config.delTree && c.config.conflict != conflictOverwrite {
+		return ErrConflictDelete
+	}
+
+	// check transaction size
+	if c.config.txnSize < 1 || c.config.txnSize > maxTxnOps {
+		return ErrTxnSize
+	}
+
+	// separate files make no sense with a bundle
+	if c.config.bundle != "" && (c.config.aclFileName != "" || c.config.queryFileName != "" ||
+		c.config.configFileName != "" || c.config.intentionFileName != "") {
+		return ErrBundleFiles
+	}
+
+	// build decryption keys
+	c.config.keys = common.NewKeys(c.config.cryptKey)
+	if c.config.identity != "" {
+		if err := c.config.keys.AddIdentities(c.config.identity); err != nil {
+			return err
+		}
+	}
+	if c.config.verifyKey != "" {
+		if err := c.config.keys.AddVerifyKey(c.config.verifyKey); err != nil {
+			return err
+		}
+	}
+
 	// populate potentially missing config items
 	cc.AddEnvDefaults(c.config.consulConfig)
 
@@ -70,3 +166,63 @@ func (c *Command) setupFlags(args []string) error {
 	// always okay
 	return nil
 }
+
+// setupBundle reads the source bundle and points all requested
+// sections present in the bundle at the source file
+func (c *Command) setupBundle() error {
+	var sections map[string]bool // requested sections
+	var err error                // error holder
+
+	// parse sections
+	if sections, err = common.ParseSections(c.config.bundle); err != nil {
+		return err
+	}
+
+	// read bundle
+	if c.bundle, err = common.ReadBundle(c.config.fileName, c.config.sigName, c.config.keys); err != nil {
+		return err
+	}
+
+	// only restore sections that were requested and are present
+	for name := range sections {
+		sections[name] = c.bundle.Has(name)
+	}
+
+	// point sections at source
+	c.config.noKV = c.config.noKV || !sections[common.SectionKV]
+	c.config.aclFileName = bundleFile(sections[common.SectionACLs], c.config.fileName)
+	c.config.queryFileName = bundleFile(sections[common.SectionQueries], c.config.fileName)
+	c.config.configFileName = bundleFile(sections[common.SectionConfigs], c.config.fileName)
+	c.config.intentionFileName = bundleFile(sections[common.SectionIntentions], c.config.fileName)
+
+	// all good
+	return nil
+}
+
+// bundleFile returns the bundle file name for included sections
+func bundleFile(included bool, fname string) string {
+	if included {
+		return fname
+	}
+	return ""
+}
+
+// readSection returns section data from the bundle when restoring
+// a bundle or reads and validates the passed backup file
+func (c *Command) readSection(name, src string) ([]byte, error) {
+	// check bundle
+	if c.bundle != nil {
+		return c.bundle.Section(name)
+	}
+	// read source
+	return common.ReadData(src, c.sigName(src), c.config.keys)
+}
+
+// sigName returns the signature location for a source
+// which is only set for the primary source
+func (c *Command) sigName(src string) string {
+	if src == c.config.fileName {
+		return c.config.sigName
+	}
+	return ""
+}
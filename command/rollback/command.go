@@ -0,0 +1,93 @@
+package rollback
+
+import (
+	"fmt"
+	stdLog "log"
+
+	"github.com/myENA/consul-backinator/command/restore"
+	"github.com/myENA/consul-backinator/common"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+)
+
+// primary configuration
+type config struct {
+	fileName     string
+	cryptKey     string
+	identity     string
+	keys         *common.Keys
+	consulConfig *ccns.Config
+}
+
+// Command is a Command implementation that runs the rollback operation
+type Command struct {
+	Self    string
+	Version string
+	Log     *stdLog.Logger
+	config  *config
+}
+
+// Run is a function to run the command
+func (c *Command) Run(args []string) int {
+	var bundle *common.Bundle // snapshot bundle
+	var err error             // error holder
+
+	// setup flags
+	if err = c.setupFlags(args); err != nil {
+		c.Log.Printf("[Error] Setup failed: %s", err.Error())
+		return 1
+	}
+
+	// read snapshot
+	if bundle, err = common.ReadBundle(c.config.fileName, "", c.config.keys); err != nil {
+		c.Log.Printf("[Error] Failed to read snapshot: %s", err.Error())
+		return 1
+	}
+
+	// show snapshot details
+	c.Log.Printf("[Info] Rolling back to snapshot of %s taken %s",
+		bundle.Manifest.Datacenter,
+		bundle.Manifest.Timestamp)
+
+	// restore the snapshot
+	return (&restore.Command{
+		Self:    c.Self,
+		Version: c.Version,
+		Log:     c.Log,
+	}).Run(c.restoreArgs(bundle.Manifest, args))
+}
+
+// Synopsis shows the command summary
+func (c *Command) Synopsis() string {
+	return "Restore a pre-restore snapshot"
+}
+
+// Help shows the detailed command options
+func (c *Command) Help() string {
+	return fmt.Sprintf(`Usage: %s rollback [options]
+
+	Restore the snapshot written before a restore operation.  All keys under
+	the snapshot prefix are replaced and the other snapshot sections restored.
+
+Options:
+
+	-file            Snapshot filename or S3 location (default: "consul.bak.pre-restore")
+	-key             Passphrase for data encryption and signature validation (default: "password")
+	-identity        Optional age identity file containing private keys for public key encrypted data
+	-addr            Optional consul address and port (default: "127.0.0.1:8500")
+	-scheme          Optional consul scheme ("http" or "https")
+	-dc              Optional consul datacenter
+	-token           Optional consul access token
+	-ca-cert         Optional path to a PEM encoded CA cert file
+	-client-cert     Optional path to a PEM encoded client certificate
+	-client-key      Optional path to an unencrypted PEM encoded private key
+	-tls-skip-verify Optional bool for verifying a TLS certificate (not recommended)
+
+	ACL objects, queries, config entries and intentions created by the
+	restore are not removed by a rollback.  Snapshots are always signed with
+	the passphrase so no verify-key is accepted.
+
+Please see documentation on GitHub for a detailed explanation of all options.
+https://github.com/myENA/consul-backinator
+
+`, c.Self)
+}
@@ -0,0 +1,92 @@
+package rollback
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/myENA/consul-backinator/common"
+	cc "github.com/myENA/consul-backinator/common/config"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+)
+
+// setupFlags initializes the instance configuration
+func (c *Command) setupFlags(args []string) error {
+	var cmdFlags *flag.FlagSet // instance flagset
+
+	// init config if needed
+	if c.config == nil {
+		c.config = new(config)
+	}
+
+	// init consul config if needed
+	if c.config.consulConfig == nil {
+		c.config.consulConfig = new(ccns.Config)
+	}
+
+	// init flagset
+	cmdFlags = flag.NewFlagSet("rollback", flag.ContinueOnError)
+	cmdFlags.Usage = func() { fmt.Fprint(os.Stdout, c.Help()); os.Exit(0) }
+
+	// declare flags
+	cmdFlags.StringVar(&c.config.fileName, "file", "consul.bak.pre-restore",
+		"Source")
+	cmdFlags.StringVar(&c.config.cryptKey, "key", "password",
+		"Passphrase for data encryption and signature validation")
+	cmdFlags.StringVar(&c.config.identity, "identity", "",
+		"Optional identity file for public key encrypted data")
+
+	// add shared flags
+	cc.AddSharedConsulFlags(cmdFlags, c.config.consulConfig)
+
+	// parse flags and ignore error
+	if err := cmdFlags.Parse(args); err != nil {
+		return nil
+	}
+
+	// check for remaining garbage
+	if cmdFlags.NArg() > 0 {
+		return cc.ErrUnknownArg
+	}
+
+	// build decryption keys
+	c.config.keys = common.NewKeys(c.config.cryptKey)
+	if c.config.identity != "" {
+		if err := c.config.keys.AddIdentities(c.config.identity); err != nil {
+			return err
+		}
+	}
+
+	// always okay
+	return nil
+}
+
+// restoreArgs builds a restore of every snapshot section that replaces
+// all keys under the snapshot prefix.  The passed arguments are forwarded
+// so the restore uses the same source, keys and consul options.
+func (c *Command) restoreArgs(m *common.Manifest, args []string) []string {
+	var sections []string // snapshot sections
+	var out []string      // restore arguments
+
+	// collect sections
+	for _, name := range common.Sections {
+		if _, ok := m.Sections[name]; ok {
+			sections = append(sections, name)
+		}
+	}
+
+	// build arguments
+	out = []string{
+		"-file", c.config.fileName,
+		"-bundle", strings.Join(sections, ","),
+		"-prefix", m.Prefix,
+		"-no-snapshot",
+	}
+	if _, ok := m.Sections[common.SectionKV]; ok {
+		out = append(out, "-delete")
+	}
+
+	// return arguments
+	return append(out, args...)
+}
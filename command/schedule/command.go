@@ -0,0 +1,119 @@
+package schedule
+
+import (
+	"fmt"
+	stdLog "log"
+	"text/template"
+
+	"github.com/myENA/consul-backinator/common"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+	"github.com/robfig/cron/v3"
+)
+
+// primary configuration
+type config struct {
+	fileName      string
+	nameTemplate  *template.Template
+	cryptKey      string
+	recipients    string
+	signKey       string
+	bundle        string
+	pathTransform string
+	cron          string
+	schedule      cron.Schedule
+	retention     *common.Retention
+	limit         int
+	lockKey       string
+	consulPrefix  string
+	consulConfig  *ccns.Config
+}
+
+// Command is a Command implementation that runs scheduled backups
+type Command struct {
+	Self         string
+	Version      string
+	Log          *stdLog.Logger
+	config       *config
+	consulClient *ccns.Client
+	written      int
+	datacenter   string
+}
+
+// Run is a function to run the command
+func (c *Command) Run(args []string) int {
+	var err error // error holder
+
+	// setup flags
+	if err = c.setupFlags(args); err != nil {
+		c.Log.Printf("[Error] Setup failed: %s", err.Error())
+		return 1
+	}
+
+	// build client
+	if c.consulClient, err = c.config.consulConfig.New(); err != nil {
+		c.Log.Printf("[Error] Failed initialize consul client: %s", err.Error())
+		return 1
+	}
+
+	// get datacenter for file names
+	if c.datacenter, err = c.getDatacenter(); err != nil {
+		c.Log.Printf("[Error] Failed to read agent datacenter: %s", err.Error())
+		return 1
+	}
+
+	// run until stopped
+	if err = c.daemon(); err != nil {
+		c.Log.Printf("[Error] %s", err.Error())
+		return 1
+	}
+
+	// exit clean
+	return 0
+}
+
+// Synopsis shows the command summary
+func (c *Command) Synopsis() string {
+	return "Run backups on a schedule and prune old backups"
+}
+
+// Help shows the detailed command options
+func (c *Command) Help() string {
+	return fmt.Sprintf(`Usage: %s schedule [options]
+
+	Writes backup bundles on a cron schedule to names built from a template
+	and removes backups outside the retention policy after each backup.
+
+Options:
+
+	-cron            Cron expression or descriptor such as "*/15 * * * *" or "@daily" (required)
+	-file            Destination filename or S3 location template (default: "consul-{{.Timestamp}}.bak")
+	-key             Passphrase for data encryption and signature validation (default: "password")
+	-recipients      Optional list of age public keys or files containing public keys to encrypt data to instead of the passphrase
+	-sign-key        Optional PEM encoded ed25519 private key file used to sign data instead of the passphrase
+	-bundle          List of sections to backup (kv,acls,queries,configs,intentions or all) (default: "kv")
+	-transform       Optional path transformation (oldPath,newPath...)
+	-keep-last       Number of most recent backups to keep (default: 0)
+	-keep-within     Keep backups modified within this duration (default: 0)
+	-keep-daily      Number of days to keep the newest backup of (default: 0)
+	-keep-weekly     Number of weeks to keep the newest backup of (default: 0)
+	-keep-monthly    Number of months to keep the newest backup of (default: 0)
+	-limit           Exit after writing this many backups (default: 0 - never)
+	-lock            Optional consul key locked so only one instance writes backups
+	-prefix          Optional prefix from under which all keys will be fetched
+	-addr            Optional consul address and port (default: "127.0.0.1:8500")
+	-scheme          Optional consul scheme ("http" or "https")
+	-dc              Optional consul datacenter
+	-token           Optional consul access token
+	-ca-cert         Optional path to a PEM encoded CA cert file
+	-client-cert     Optional path to a PEM encoded client certificate
+	-client-key      Optional path to an unencrypted PEM encoded private key
+	-tls-skip-verify Optional bool for verifying a TLS certificate (not recommended)
+
+	File templates may use {{.Datacenter}}, {{.Prefix}}, {{.Date}}, {{.Time}},
+	{{.Timestamp}} and {{.Unix}}.  Nothing is pruned without a retention option.
+
+Please see documentation on GitHub for a detailed explanation of all options.
+https://github.com/myENA/consul-backinator
+
+`, c.Self)
+}
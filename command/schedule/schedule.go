@@ -0,0 +1,116 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/myENA/consul-backinator/command/backup"
+	"github.com/myENA/consul-backinator/common"
+)
+
+// daemon runs run until stopped holding the
+// lock when requested so only one instance writes backups
+func (c *Command) daemon() error {
+	var stop <-chan struct{} // stop signal
+	var cancel func()        // signal cleanup
+
+	// stop cleanly when asked
+	stop, cancel = common.StopOnSignal(c.Log)
+	defer cancel()
+
+	// run without a lock
+	if c.config.lockKey == "" {
+		return c.run(stop)
+	}
+
+	// run while holding the lock
+	return c.consulClient.RunLocked(c.config.lockKey, stop, c.run)
+}
+
+// run writes backups on schedule until done is closed
+func (c *Command) run(done <-chan struct{}) error {
+	// loop until stopped
+	for {
+		var next time.Time // next run
+
+		// wait for the next run
+		next = c.config.schedule.Next(time.Now())
+		c.Log.Printf("[Info] Next backup scheduled for %s", next.Format(time.RFC3339))
+		select {
+		case <-time.After(time.Until(next)):
+		case <-done:
+			return nil
+		}
+
+		// backup and prune - failures are retried on the next run
+		if err := c.runBackup(next); err != nil {
+			c.Log.Printf("[Warning] %s", err.Error())
+			continue
+		}
+		if err := c.prune(); err != nil {
+			c.Log.Printf("[Warning] Failed to prune backups: %s", err.Error())
+		}
+
+		// check limit
+		if c.written++; c.config.limit > 0 && c.written >= c.config.limit {
+			return nil
+		}
+	}
+}
+
+// runBackup writes a backup to the name rendered for the passed time
+func (c *Command) runBackup(t time.Time) error {
+	var dest string // bundle location
+	var err error   // general error holder
+
+	// render name
+	if dest, err = c.name(t); err != nil {
+		return fmt.Errorf("Failed to render file name: %s", err.Error())
+	}
+
+	// run backup
+	if status := (&backup.Command{
+		Self:       c.Self,
+		Version:    c.Version,
+		AllowEmpty: true,
+		Log:        c.Log,
+	}).Run(c.backupArgs(dest)); status != 0 {
+		return fmt.Errorf("Failed to write backup to %s", dest)
+	}
+
+	// all good
+	return nil
+}
+
+// prune removes backups written by the schedule that
+// are outside the retention policy
+func (c *Command) prune() error {
+	var pattern string               // backup name pattern
+	var backups []*common.BackupInfo // existing backups
+	var err error                    // general error holder
+
+	// nothing to do without a policy
+	if c.config.retention.Empty() {
+		return nil
+	}
+
+	// find backups
+	if pattern, err = c.pattern(); err != nil {
+		return err
+	}
+	if backups, err = common.ListBackups(pattern); err != nil {
+		return err
+	}
+
+	// remove expired backups
+	for _, b := range c.config.retention.Expired(backups) {
+		if err = common.DeleteBackup(b.Name); err != nil {
+			c.Log.Printf("[Warning] Failed to remove backup %s: %s", b.Name, err.Error())
+			continue
+		}
+		c.Log.Printf("[Info] Removed backup %s outside retention policy", b.Name)
+	}
+
+	// all good
+	return nil
+}
@@ -0,0 +1,234 @@
+package schedule
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/myENA/consul-backinator/common"
+	cc "github.com/myENA/consul-backinator/common/config"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+	"github.com/robfig/cron/v3"
+)
+
+// Exported option errors
+var (
+	ErrMissingCron = errors.New("The 'cron' option is required")
+	ErrWildPattern = errors.New("The 'file' template must contain fixed text in the " +
+		"file name when using a retention option")
+)
+
+// nameData contains the fields available to file name templates
+type nameData struct {
+	Datacenter string
+	Prefix     string
+	Date       string
+	Time       string
+	Timestamp  string
+	Unix       string
+}
+
+// setupFlags initializes the instance configuration
+func (c *Command) setupFlags(args []string) error {
+	var cmdFlags *flag.FlagSet // instance flagset
+	var err error              // error holder
+
+	// init config if needed
+	if c.config == nil {
+		c.config = new(config)
+	}
+
+	// init consul config if needed
+	if c.config.consulConfig == nil {
+		c.config.consulConfig = new(ccns.Config)
+	}
+
+	// init retention
+	c.config.retention = new(common.Retention)
+
+	// init flagset
+	cmdFlags = flag.NewFlagSet("schedule", flag.ContinueOnError)
+	cmdFlags.Usage = func() { fmt.Fprint(os.Stdout, c.Help()); os.Exit(0) }
+
+	// declare flags
+	cmdFlags.StringVar(&c.config.cron, "cron", "",
+		"Cron expression or descriptor")
+	cmdFlags.StringVar(&c.config.fileName, "file", "consul-{{.Timestamp}}.bak",
+		"Destination template")
+	cmdFlags.StringVar(&c.config.cryptKey, "key", "password",
+		"Passphrase for data encryption and signature validation")
+	cmdFlags.StringVar(&c.config.recipients, "recipients", "",
+		"Optional list of public keys to encrypt data to")
+	cmdFlags.StringVar(&c.config.signKey, "sign-key", "",
+		"Optional private key file used to sign data")
+	cmdFlags.StringVar(&c.config.bundle, "bundle", common.SectionKV,
+		"List of sections to backup")
+	cmdFlags.StringVar(&c.config.pathTransform, "transform", "",
+		"Optional path transformation")
+	cmdFlags.IntVar(&c.config.retention.Last, "keep-last", 0,
+		"Number of most recent backups to keep")
+	cmdFlags.DurationVar(&c.config.retention.Within, "keep-within", 0,
+		"Keep backups modified within this duration")
+	cmdFlags.IntVar(&c.config.retention.Daily, "keep-daily", 0,
+		"Number of days to keep the newest backup of")
+	cmdFlags.IntVar(&c.config.retention.Weekly, "keep-weekly", 0,
+		"Number of weeks to keep the newest backup of")
+	cmdFlags.IntVar(&c.config.retention.Monthly, "keep-monthly", 0,
+		"Number of months to keep the newest backup of")
+	cmdFlags.IntVar(&c.config.limit, "limit", 0,
+		"Exit after writing this many backups")
+	cmdFlags.StringVar(&c.config.lockKey, "lock", "",
+		"Optional consul key locked so only one instance writes backups")
+	cmdFlags.StringVar(&c.config.consulPrefix, "prefix", "/",
+		"Optional prefix from under which all keys will be fetched")
+
+	// add shared flags
+	cc.AddSharedConsulFlags(cmdFlags, c.config.consulConfig)
+
+	// parse flags and ignore error
+	if err = cmdFlags.Parse(args); err != nil {
+		return nil
+	}
+
+	// check for remaining garbage
+	if cmdFlags.NArg() > 0 {
+		return cc.ErrUnknownArg
+	}
+
+	// parse schedule
+	if c.config.cron == "" {
+		return ErrMissingCron
+	}
+	if c.config.schedule, err = cron.ParseStandard(c.config.cron); err != nil {
+		return err
+	}
+
+	// parse file name template
+	if c.config.nameTemplate, err = template.New("file").Option("missingkey=error").
+		Parse(c.config.fileName); err != nil {
+		return err
+	}
+
+	// check bundle sections
+	if _, err = common.ParseSections(c.config.bundle); err != nil {
+		return err
+	}
+
+	// populate potentially missing config items
+	cc.AddEnvDefaults(c.config.consulConfig)
+
+	// fixup prefix per upstream issue 2403
+	// https://github.com/hashicorp/consul/issues/2403
+	c.config.consulPrefix = strings.TrimPrefix(c.config.consulPrefix,
+		ccns.Separator)
+
+	// make sure retention never matches unrelated files
+	if !c.config.retention.Empty() {
+		var pattern string // retention pattern
+		if pattern, err = c.pattern(); err != nil {
+			return err
+		}
+		if strings.Trim(path.Base(pattern), "*") == "" {
+			return ErrWildPattern
+		}
+	}
+
+	// always okay
+	return nil
+}
+
+// name renders the file name template for a backup taken at the passed time
+func (c *Command) name(t time.Time) (string, error) {
+	t = t.UTC()
+	return c.render(&nameData{
+		Date:      t.Format("20060102"),
+		Time:      t.Format("150405"),
+		Timestamp: t.Format("20060102T150405Z"),
+		Unix:      strconv.FormatInt(t.Unix(), 10),
+	})
+}
+
+// pattern renders the file name template with wildcards in place
+// of the time fields to match all backups written by the schedule
+func (c *Command) pattern() (string, error) {
+	return c.render(&nameData{
+		Date:      "*",
+		Time:      "*",
+		Timestamp: "*",
+		Unix:      "*",
+	})
+}
+
+// render executes the file name template with the
+// passed time fields and the source details
+func (c *Command) render(data *nameData) (string, error) {
+	var buf bytes.Buffer // rendered name
+
+	// add source details
+	data.Datacenter = c.datacenter
+	data.Prefix = strings.Replace(strings.Trim(c.config.consulPrefix, ccns.Separator),
+		ccns.Separator, "-", -1)
+	if data.Prefix == "" {
+		data.Prefix = "root"
+	}
+
+	// render template
+	if err := c.config.nameTemplate.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	// return name
+	return buf.String(), nil
+}
+
+// getDatacenter returns the passed datacenter or the agent datacenter
+func (c *Command) getDatacenter() (string, error) {
+	var self map[string]map[string]interface{} // agent information
+	var err error                              // error holder
+
+	// use passed datacenter
+	if c.config.consulConfig.Datacenter != "" {
+		return c.config.consulConfig.Datacenter, nil
+	}
+
+	// ask the agent
+	if self, err = c.consulClient.Agent().Self(); err != nil {
+		return "", err
+	}
+	dc, _ := self["Config"]["Datacenter"].(string)
+
+	// return datacenter
+	return dc, nil
+}
+
+// backupArgs builds the arguments for a backup to the passed destination
+func (c *Command) backupArgs(dest string) []string {
+	var args []string // backup arguments
+
+	// build arguments
+	args = []string{
+		"-file", dest,
+		"-key", c.config.cryptKey,
+		"-bundle", c.config.bundle,
+		"-prefix", c.config.consulPrefix,
+	}
+	for _, f := range []struct{ name, value string }{
+		{"recipients", c.config.recipients},
+		{"sign-key", c.config.signKey},
+		{"transform", c.config.pathTransform},
+	} {
+		if f.value != "" {
+			args = append(args, "-"+f.name, f.value)
+		}
+	}
+
+	// return arguments with consul options
+	return append(args, cc.SharedConsulArgs(c.config.consulConfig)...)
+}
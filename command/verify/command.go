@@ -0,0 +1,98 @@
+package verify
+
+import (
+	"fmt"
+	stdLog "log"
+
+	"github.com/myENA/consul-backinator/common"
+)
+
+// primary configuration
+type config struct {
+	fileName   string
+	sigName    string
+	cryptKey   string
+	identity   string
+	verifyKey  string
+	sigOnly    bool
+	keys       *common.Keys
+	acls       bool
+	queries    bool
+	configs    bool
+	intentions bool
+	jsonOutput bool
+}
+
+// Command is a Command implementation that runs the verify operation
+type Command struct {
+	Self   string
+	Log    *stdLog.Logger
+	config *config
+}
+
+// Run is a function to run the command
+func (c *Command) Run(args []string) int {
+	var report *Report // verification report
+	var err error      // error holder
+
+	// setup flags
+	if err = c.setupFlags(args); err != nil {
+		c.Log.Printf("[Error] Setup failed: %s", err.Error())
+		return 1
+	}
+
+	// verify backup
+	report = c.verify()
+
+	// print report
+	if err = c.printReport(report); err != nil {
+		c.Log.Printf("[Error] Failed to print report: %s", err.Error())
+		return 1
+	}
+
+	// check result
+	if !report.Valid {
+		c.Log.Printf("[Error] Verification of %s failed", c.config.fileName)
+		return 1
+	}
+
+	// show success
+	c.Log.Printf("[Success] Verified %s", c.config.fileName)
+
+	// exit clean
+	return 0
+}
+
+// Synopsis shows the command summary
+func (c *Command) Synopsis() string {
+	return "Verify a backup file"
+}
+
+// Help shows the detailed command options
+func (c *Command) Help() string {
+	return fmt.Sprintf(`Usage: %s verify [options]
+
+	Validate the signature and contents of a backup file without restoring it.
+
+Options:
+
+	-file         Source filename, S3 location or "-" to read from stdin (default: "consul.bak")
+	-sig          Optional signature location used instead of next to the source or embedded in the stdin stream
+	-key          Passphrase for data encryption and signature validation (default: "password")
+	-identity     Optional age identity file containing private keys for public key encrypted data
+	-verify-key   Optional PEM encoded ed25519 public key file used to validate signed data
+	-acls         Specified file is an ACL backup file
+	-queries      Specified file is a prepared query backup file
+	-configs      Specified file is a config entry backup file
+	-intentions   Specified file is an intention backup file
+	-json         Print the verification report as JSON
+
+	When the specified file is a bundle every section in the bundle is verified.
+	When only a verify-key is passed the signature is validated against the
+	encrypted data without decrypting it and the contents are not checked.
+
+Please see documentation on GitHub for a detailed explanation of all options.
+https://github.com/myENA/consul-backinator
+
+`, c.Self)
+}
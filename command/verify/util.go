@@ -0,0 +1,105 @@
+package verify
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/myENA/consul-backinator/common"
+	cc "github.com/myENA/consul-backinator/common/config"
+)
+
+// setupFlags initializes the instance configuration
+func (c *Command) setupFlags(args []string) error {
+	var cmdFlags *flag.FlagSet // instance flagset
+
+	// init config if needed
+	if c.config == nil {
+		c.config = new(config)
+	}
+
+	// init flagset
+	cmdFlags = flag.NewFlagSet("verify", flag.ContinueOnError)
+	cmdFlags.Usage = func() { fmt.Fprint(os.Stdout, c.Help()); os.Exit(0) }
+
+	// declare flags
+	cmdFlags.StringVar(&c.config.fileName, "file", "consul.bak",
+		"Source")
+	cmdFlags.StringVar(&c.config.sigName, "sig", "",
+		"Optional signature location")
+	cmdFlags.StringVar(&c.config.cryptKey, "key", "password",
+		"Passphrase for data encryption and signature validation")
+	cmdFlags.StringVar(&c.config.identity, "identity", "",
+		"Optional identity file for public key encrypted data")
+	cmdFlags.StringVar(&c.config.verifyKey, "verify-key", "",
+		"Optional public key file used to validate signed data")
+	cmdFlags.BoolVar(&c.config.acls, "acls", false,
+		"Specified file is an ACL backup file")
+	cmdFlags.BoolVar(&c.config.queries, "queries", false,
+		"Specified file is a prepared query backup file")
+	cmdFlags.BoolVar(&c.config.configs, "configs", false,
+		"Specified file is a config entry backup file")
+	cmdFlags.BoolVar(&c.config.intentions, "intentions", false,
+		"Specified file is an intention backup file")
+	cmdFlags.BoolVar(&c.config.jsonOutput, "json", false,
+		"Print the verification report as JSON")
+
+	// parse flags and ignore error
+	if err := cmdFlags.Parse(args); err != nil {
+		return nil
+	}
+
+	// check for remaining garbage
+	if cmdFlags.NArg() > 0 {
+		return cc.ErrUnknownArg
+	}
+
+	// only the source may be streamed
+	if err := cc.CheckStdio(c.config.sigName); err != nil {
+		return err
+	}
+
+	// only validate the signature when given nothing able to decrypt
+	c.config.sigOnly = c.config.verifyKey != "" && c.config.identity == "" && !passed(cmdFlags, "key")
+
+	// build decryption keys
+	c.config.keys = common.NewKeys(c.config.cryptKey)
+	if c.config.identity != "" {
+		if err := c.config.keys.AddIdentities(c.config.identity); err != nil {
+			return err
+		}
+	}
+	if c.config.verifyKey != "" {
+		if err := c.config.keys.AddVerifyKey(c.config.verifyKey); err != nil {
+			return err
+		}
+	}
+
+	// always okay
+	return nil
+}
+
+// passed checks if a flag was set on the command line
+func passed(cmdFlags *flag.FlagSet, name string) bool {
+	var found bool // flag seen
+	cmdFlags.Visit(func(f *flag.Flag) {
+		found = found || f.Name == name
+	})
+	return found
+}
+
+// section returns the section name matching the requested data type
+func (c *Command) section() string {
+	switch {
+	case c.config.acls:
+		return common.SectionACLs
+	case c.config.queries:
+		return common.SectionQueries
+	case c.config.configs:
+		return common.SectionConfigs
+	case c.config.intentions:
+		return common.SectionIntentions
+	default:
+		return common.SectionKV
+	}
+}
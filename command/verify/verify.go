@@ -0,0 +1,368 @@
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/acl"
+	"github.com/myENA/consul-backinator/common/intention"
+	"github.com/myENA/consul-backinator/common/kv"
+)
+
+// Report describes the verification result of a backup file
+type Report struct {
+	File          string
+	Signer        string `json:",omitempty"`
+	SignatureOnly bool   `json:",omitempty"`
+	Bundle        bool
+	Valid         bool
+	Error         string `json:",omitempty"`
+	Sections      []*Result
+}
+
+// Result describes the verification result of a single backup section
+type Result struct {
+	Section   string
+	Count     int
+	Size      int
+	Malformed []string `json:",omitempty"`
+	Error     string   `json:",omitempty"`
+}
+
+// verify reads the backup file, validates the signature and
+// decodes all contained sections into the expected types
+func (c *Command) verify() *Report {
+	var report *Report        // output report
+	var bundle *common.Bundle // decoded bundle
+	var data []byte           // read json data
+	var err error             // general error holder
+
+	// init report
+	report = &Report{
+		File:  c.config.fileName,
+		Valid: true,
+	}
+
+	// record expected signer
+	if c.config.keys.Verifier != nil {
+		report.Signer = common.Fingerprint(c.config.keys.Verifier)
+	}
+
+	// validate the signature without decrypting when only given the public key
+	if c.config.sigOnly {
+		report.SignatureOnly = true
+		if err = common.VerifySignature(c.config.fileName, c.config.sigName, c.config.keys); err != nil {
+			report.Valid = false
+			report.Error = err.Error()
+		}
+		return report
+	}
+
+	// read, validate and decode data
+	if data, err = common.ReadData(c.config.fileName, c.config.sigName, c.config.keys); err != nil {
+		report.Valid = false
+		report.Error = err.Error()
+		return report
+	}
+
+	// check for bundle
+	if !common.IsBundle(data) {
+		report.add(c.section(), data, -1)
+		return report
+	}
+
+	// decode bundle
+	report.Bundle = true
+	if bundle, err = common.DecodeBundle(data); err != nil {
+		report.Valid = false
+		report.Error = err.Error()
+		return report
+	}
+
+	// verify all sections in restore order
+	for _, name := range common.Sections {
+		var info *common.SectionInfo // manifest info
+		var ok bool                  // presence check
+		if info, ok = bundle.Manifest.Sections[name]; !ok {
+			continue
+		}
+		// validate section hash
+		if data, err = bundle.Section(name); err != nil {
+			report.Valid = false
+			report.Sections = append(report.Sections, &Result{
+				Section: name,
+				Error:   err.Error(),
+			})
+			continue
+		}
+		// check section contents against the manifest count
+		report.add(name, data, info.Count)
+	}
+
+	// all done
+	return report
+}
+
+// add checks section data and adds the result to the report.
+// The decoded count is compared to the expected count when not negative.
+func (r *Report) add(name string, data []byte, expected int) {
+	var result *Result // section result
+	var err error      // general error holder
+
+	// init result
+	result = &Result{
+		Section: name,
+		Size:    len(data),
+	}
+
+	// check data
+	if result.Count, result.Malformed, err = check(name, data); err != nil {
+		result.Error = err.Error()
+	} else if expected >= 0 && expected != result.Count {
+		result.Error = fmt.Sprintf("section contains %d items but manifest lists %d",
+			result.Count, expected)
+	}
+
+	// update validity
+	if result.Error != "" || len(result.Malformed) > 0 {
+		r.Valid = false
+	}
+
+	// add result
+	r.Sections = append(r.Sections, result)
+}
+
+// check decodes section data into the expected type and returns
+// the item count and a description of any malformed entries
+func check(name string, data []byte) (int, []string, error) {
+	switch name {
+	case common.SectionACLs:
+		return checkACLs(data)
+	case common.SectionQueries:
+		return checkEntries(data, func(raw json.RawMessage) error {
+			var query api.PreparedQueryDefinition // decoded query
+			if err := json.Unmarshal(raw, &query); err != nil {
+				return err
+			}
+			if query.Service.Service == "" {
+				return fmt.Errorf("query %s has no service", query.ID)
+			}
+			return nil
+		})
+	case common.SectionConfigs:
+		return checkEntries(data, func(raw json.RawMessage) error {
+			var header struct{ Kind, Name string } // entry kind and name
+			var entry api.ConfigEntry              // typed entry
+			var err error                          // general error holder
+			if err = json.Unmarshal(raw, &header); err != nil {
+				return err
+			}
+			if header.Name == "" {
+				return fmt.Errorf("%s entry has no name", header.Kind)
+			}
+			if entry, err = api.MakeConfigEntry(header.Kind, header.Name); err != nil {
+				return err
+			}
+			return json.Unmarshal(raw, entry)
+		})
+	case common.SectionIntentions:
+		return checkIntentions(data)
+	default:
+		// increments are not arrays of pairs
+		if kv.IsIncrement(data) {
+			return checkIncrement(data)
+		}
+		return checkEntries(data, func(raw json.RawMessage) error {
+			var kv api.KVPair // decoded pair
+			if err := json.Unmarshal(raw, &kv); err != nil {
+				return err
+			}
+			if kv.Key == "" {
+				return fmt.Errorf("pair has no key")
+			}
+			return nil
+		})
+	}
+}
+
+// checkEntries splits a json array and checks each entry with the passed function.
+// Entries that fail the check are reported as malformed but still counted.
+func checkEntries(data []byte, fn func(json.RawMessage) error) (int, []string, error) {
+	var raw []json.RawMessage // undecoded entries
+	var malformed []string    // malformed entries
+	var err error             // general error holder
+
+	// split entries
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return 0, nil, err
+	}
+
+	// check entries
+	for i, r := range raw {
+		if err = fn(r); err != nil {
+			malformed = append(malformed, fmt.Sprintf("entry %d: %s", i, err.Error()))
+		}
+	}
+
+	// return count
+	return len(raw), malformed, nil
+}
+
+// checkIncrement decodes a kv increment and checks changed keys and tombstones
+func checkIncrement(data []byte) (int, []string, error) {
+	var inc *kv.Increment  // decoded increment
+	var malformed []string // malformed entries
+	var err error          // general error holder
+
+	// decode increment
+	if inc, err = kv.DecodeIncrement(data); err != nil {
+		return 0, nil, err
+	}
+
+	// check keys
+	for i, pair := range inc.Pairs {
+		if pair.Key == "" {
+			malformed = append(malformed, fmt.Sprintf("entry %d: pair has no key", i))
+		}
+	}
+	for i, key := range inc.Deleted {
+		if key == "" {
+			malformed = append(malformed, fmt.Sprintf("tombstone %d: no key", i))
+		}
+	}
+
+	// return count
+	return inc.Count(), malformed, nil
+}
+
+// checkACLs decodes acl data and checks required identifiers
+func checkACLs(data []byte) (int, []string, error) {
+	var snap *acl.Snapshot     // acl snapshot
+	var legacy []*api.ACLEntry // legacy acl entries
+	var malformed []string     // malformed entries
+	var err error              // general error holder
+
+	// decode data
+	if snap, legacy, err = acl.Decode(data); err != nil {
+		return 0, nil, err
+	}
+
+	// check legacy entries
+	if snap == nil {
+		for i, entry := range legacy {
+			if entry.ID == "" {
+				malformed = append(malformed, fmt.Sprintf("token %d: missing id", i))
+			}
+		}
+		return len(legacy), malformed, nil
+	}
+
+	// check snapshot objects
+	for i, policy := range snap.Policies {
+		if policy.Name == "" {
+			malformed = append(malformed, fmt.Sprintf("policy %d: missing name", i))
+		}
+	}
+	for i, role := range snap.Roles {
+		if role.Name == "" {
+			malformed = append(malformed, fmt.Sprintf("role %d: missing name", i))
+		}
+	}
+	for i, method := range snap.AuthMethods {
+		if method.Name == "" || method.Type == "" {
+			malformed = append(malformed, fmt.Sprintf("auth method %d: missing name or type", i))
+		}
+	}
+	for i, rule := range snap.BindingRules {
+		if rule.AuthMethod == "" {
+			malformed = append(malformed, fmt.Sprintf("binding rule %d: missing auth method", i))
+		}
+	}
+	for i, token := range snap.Tokens {
+		switch {
+		case token.ACLToken == nil:
+			malformed = append(malformed, fmt.Sprintf("token %d: missing token", i))
+		case token.Legacy && token.AccessorID == "":
+			// legacy tokens backed up before being assigned an accessor are restored by secret
+			if token.SecretID == "" {
+				malformed = append(malformed, fmt.Sprintf("token %d: missing secret id", i))
+			}
+		case token.AccessorID == "":
+			malformed = append(malformed, fmt.Sprintf("token %d: missing accessor id", i))
+		}
+	}
+
+	// return count
+	return snap.Count(), malformed, nil
+}
+
+// checkIntentions decodes intention data and checks source and destination names
+func checkIntentions(data []byte) (int, []string, error) {
+	var snap *intention.Snapshot // intention snapshot
+	var malformed []string       // malformed entries
+	var err error                // general error holder
+
+	// decode data
+	if snap, err = intention.Decode(data); err != nil {
+		return 0, nil, err
+	}
+
+	// check intentions
+	for i, ixn := range snap.Intentions {
+		if ixn.SourceName == "" || ixn.DestinationName == "" {
+			malformed = append(malformed, fmt.Sprintf("intention %d: missing source or destination", i))
+		}
+	}
+
+	// return count
+	return len(snap.Intentions), malformed, nil
+}
+
+// printReport prints the verification report to stdout
+func (c *Command) printReport(r *Report) error {
+	var data []byte // encoded report
+	var err error   // general error holder
+
+	// check output format
+	if c.config.jsonOutput {
+		// encode report
+		if data, err = json.MarshalIndent(r, "", "  "); err != nil {
+			return err
+		}
+		// write payload
+		os.Stdout.Write(data)
+		// write a blank line
+		os.Stdout.WriteString("\n")
+		// all done
+		return nil
+	}
+
+	// print summary
+	fmt.Printf("File: %s\nBundle: %t\nValid: %t\n", r.File, r.Bundle, r.Valid)
+	if r.Signer != "" {
+		fmt.Printf("Signer: %s\n", r.Signer)
+	}
+	if r.SignatureOnly {
+		fmt.Printf("Signature Only: %t\n", r.SignatureOnly)
+	}
+	if r.Error != "" {
+		fmt.Printf("Error: %s\n", r.Error)
+	}
+
+	// print sections
+	for _, s := range r.Sections {
+		fmt.Printf("Section: %s (%d items, %d bytes)\n", s.Section, s.Count, s.Size)
+		if s.Error != "" {
+			fmt.Printf("  Error: %s\n", s.Error)
+		}
+		for _, m := range s.Malformed {
+			fmt.Printf("  Malformed: %s\n", m)
+		}
+	}
+
+	// okay
+	return nil
+}
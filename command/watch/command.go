@@ -0,0 +1,109 @@
+package watch
+
+import (
+	"fmt"
+	stdLog "log"
+	"time"
+
+	ccns "github.com/myENA/consul-backinator/common/consul"
+)
+
+// primary configuration
+type config struct {
+	fileName      string
+	cryptKey      string
+	recipients    string
+	signKey       string
+	identity      string
+	bundle        string
+	sections      map[string]bool
+	pathTransform string
+	incremental   bool
+	debounce      time.Duration
+	wait          time.Duration
+	limit         int
+	lockKey       string
+	consulPrefix  string
+	consulConfig  *ccns.Config
+}
+
+// Command is a Command implementation that runs the watch operation
+type Command struct {
+	Self         string
+	Version      string
+	Log          *stdLog.Logger
+	config       *config
+	consulClient *ccns.Client
+	written      int
+}
+
+// Run is a function to run the command
+func (c *Command) Run(args []string) int {
+	var err error // error holder
+
+	// setup flags
+	if err = c.setupFlags(args); err != nil {
+		c.Log.Printf("[Error] Setup failed: %s", err.Error())
+		return 1
+	}
+
+	// build client
+	if c.consulClient, err = c.config.consulConfig.New(); err != nil {
+		c.Log.Printf("[Error] Failed initialize consul client: %s", err.Error())
+		return 1
+	}
+
+	// watch until stopped
+	if err = c.daemon(); err != nil {
+		c.Log.Printf("[Error] %s", err.Error())
+		return 1
+	}
+
+	// exit clean
+	return 0
+}
+
+// Synopsis shows the command summary
+func (c *Command) Synopsis() string {
+	return "Continuously backup data as it changes"
+}
+
+// Help shows the detailed command options
+func (c *Command) Help() string {
+	return fmt.Sprintf(`Usage: %s watch [options]
+
+	Watches a consul cluster with blocking queries and writes a new backup
+	bundle whenever the watched data changes.
+
+Options:
+
+	-file            Destination filename or S3 location (default: "consul.bak")
+	-key             Passphrase for data encryption and signature validation (default: "password")
+	-recipients      Optional list of age public keys or files containing public keys to encrypt data to instead of the passphrase
+	-sign-key        Optional PEM encoded ed25519 private key file used to sign data instead of the passphrase
+	-identity        Optional age identity file used to read the previous bundle with incremental
+	-bundle          List of sections to watch and backup (kv,acls,queries,configs,intentions or all) (default: "kv")
+	-transform       Optional path transformation (oldPath,newPath...)
+	-incremental     Write a new full backup on start and increments chained to it, all with a timestamp suffix (default: false)
+	-debounce        Time to wait for changes to settle before writing a backup (default: 10s)
+	-wait            Maximum blocking query wait time (default: 5m)
+	-limit           Exit after writing this many backups (default: 0 - never)
+	-lock            Optional consul key locked so only one instance writes backups
+	-prefix          Optional prefix from under which all keys will be fetched
+	-addr            Optional consul address and port (default: "127.0.0.1:8500")
+	-scheme          Optional consul scheme ("http" or "https")
+	-dc              Optional consul datacenter
+	-token           Optional consul access token
+	-ca-cert         Optional path to a PEM encoded CA cert file
+	-client-cert     Optional path to a PEM encoded client certificate
+	-client-key      Optional path to an unencrypted PEM encoded private key
+	-tls-skip-verify Optional bool for verifying a TLS certificate (not recommended)
+
+	Full backups replace the destination.  Increments are written next to
+	the destination with a timestamp suffix.
+
+Please see documentation on GitHub for a detailed explanation of all options.
+https://github.com/myENA/consul-backinator
+
+`, c.Self)
+}
@@ -0,0 +1,133 @@
+package watch
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/myENA/consul-backinator/common"
+	cc "github.com/myENA/consul-backinator/common/config"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+)
+
+// Exported option errors
+var (
+	ErrIncrementalKV = errors.New("The 'incremental' option requires the 'kv' bundle section")
+	ErrDuration      = errors.New("The 'debounce' and 'wait' options must be positive durations")
+)
+
+// setupFlags initializes the instance configuration
+func (c *Command) setupFlags(args []string) error {
+	var cmdFlags *flag.FlagSet // instance flagset
+	var err error              // error holder
+
+	// init config if needed
+	if c.config == nil {
+		c.config = new(config)
+	}
+
+	// init consul config if needed
+	if c.config.consulConfig == nil {
+		c.config.consulConfig = new(ccns.Config)
+	}
+
+	// init flagset
+	cmdFlags = flag.NewFlagSet("watch", flag.ContinueOnError)
+	cmdFlags.Usage = func() { fmt.Fprint(os.Stdout, c.Help()); os.Exit(0) }
+
+	// declare flags
+	cmdFlags.StringVar(&c.config.fileName, "file", "consul.bak",
+		"Destination")
+	cmdFlags.StringVar(&c.config.cryptKey, "key", "password",
+		"Passphrase for data encryption and signature validation")
+	cmdFlags.StringVar(&c.config.recipients, "recipients", "",
+		"Optional list of public keys to encrypt data to")
+	cmdFlags.StringVar(&c.config.signKey, "sign-key", "",
+		"Optional private key file used to sign data")
+	cmdFlags.StringVar(&c.config.identity, "identity", "",
+		"Optional identity file used to read the previous bundle")
+	cmdFlags.StringVar(&c.config.bundle, "bundle", common.SectionKV,
+		"List of sections to watch and backup")
+	cmdFlags.StringVar(&c.config.pathTransform, "transform", "",
+		"Optional path transformation")
+	cmdFlags.BoolVar(&c.config.incremental, "incremental", false,
+		"Write increments after the first full backup")
+	cmdFlags.DurationVar(&c.config.debounce, "debounce", 10*time.Second,
+		"Time to wait for changes to settle")
+	cmdFlags.DurationVar(&c.config.wait, "wait", 5*time.Minute,
+		"Maximum blocking query wait time")
+	cmdFlags.IntVar(&c.config.limit, "limit", 0,
+		"Exit after writing this many backups")
+	cmdFlags.StringVar(&c.config.lockKey, "lock", "",
+		"Optional consul key locked so only one instance writes backups")
+	cmdFlags.StringVar(&c.config.consulPrefix, "prefix", "/",
+		"Optional prefix from under which all keys will be fetched")
+
+	// add shared flags
+	cc.AddSharedConsulFlags(cmdFlags, c.config.consulConfig)
+
+	// parse flags and ignore error
+	if err = cmdFlags.Parse(args); err != nil {
+		return nil
+	}
+
+	// check for remaining garbage
+	if cmdFlags.NArg() > 0 {
+		return cc.ErrUnknownArg
+	}
+
+	// parse sections
+	if c.config.sections, err = common.ParseSections(c.config.bundle); err != nil {
+		return err
+	}
+	if c.config.incremental && !c.config.sections[common.SectionKV] {
+		return ErrIncrementalKV
+	}
+
+	// check durations
+	if c.config.debounce <= 0 || c.config.wait <= 0 {
+		return ErrDuration
+	}
+
+	// populate potentially missing config items
+	cc.AddEnvDefaults(c.config.consulConfig)
+
+	// fixup prefix per upstream issue 2403
+	// https://github.com/hashicorp/consul/issues/2403
+	c.config.consulPrefix = strings.TrimPrefix(c.config.consulPrefix,
+		ccns.Separator)
+
+	// always okay
+	return nil
+}
+
+// backupArgs builds the arguments for a backup to the passed destination
+// chained to the previous bundle when not empty
+func (c *Command) backupArgs(dest, previous string) []string {
+	var args []string // backup arguments
+
+	// build arguments
+	args = []string{
+		"-file", dest,
+		"-key", c.config.cryptKey,
+		"-bundle", c.config.bundle,
+		"-prefix", c.config.consulPrefix,
+	}
+	for _, f := range []struct{ name, value string }{
+		{"recipients", c.config.recipients},
+		{"sign-key", c.config.signKey},
+		{"identity", c.config.identity},
+		{"transform", c.config.pathTransform},
+		{"incremental", previous},
+	} {
+		if f.value != "" {
+			args = append(args, "-"+f.name, f.value)
+		}
+	}
+
+	// return arguments with consul options
+	return append(args, cc.SharedConsulArgs(c.config.consulConfig)...)
+}
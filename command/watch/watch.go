@@ -0,0 +1,261 @@
+package watch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/command/backup"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/configentry"
+)
+
+// Watch timing limits
+const (
+	retryInterval = 5 * time.Second // wait after a failed query or backup
+	minInterval   = time.Second     // minimum time between queries
+	maxDebounce   = 10              // debounce multiple a backup may be delayed
+	suffixFormat  = "20060102T150405.000Z"
+)
+
+// watchFunc runs a blocking query and returns the resulting index
+type watchFunc func(opts *api.QueryOptions) (uint64, error)
+
+// daemon runs watch until stopped holding the
+// lock when requested so only one instance writes backups
+func (c *Command) daemon() error {
+	var stop <-chan struct{} // stop signal
+	var cancel func()        // signal cleanup
+
+	// stop cleanly when asked
+	stop, cancel = common.StopOnSignal(c.Log)
+	defer cancel()
+
+	// run without a lock
+	if c.config.lockKey == "" {
+		return c.watch(stop)
+	}
+
+	// run while holding the lock
+	return c.consulClient.RunLocked(c.config.lockKey, stop, c.watch)
+}
+
+// watch writes a full backup and then writes a new backup
+// each time the watched data changes until done is closed
+func (c *Command) watch(done <-chan struct{}) error {
+	var changes chan string   // changed endpoint names
+	var stop chan struct{}    // watcher stop signal
+	var fire <-chan time.Time // debounce timer
+	var deadline time.Time    // latest time a pending backup may be written
+	var previous string       // previous bundle location
+	var err error             // general error holder
+
+	// start watchers before the first backup so no change is missed
+	changes = make(chan string)
+	stop = make(chan struct{})
+	defer close(stop)
+	for name, fn := range c.watchers() {
+		go c.poll(name, fn, changes, stop)
+	}
+
+	// write the initial full backup
+	if previous, err = c.runBackup(""); err != nil {
+		return err
+	}
+	if c.written++; c.config.limit > 0 && c.written >= c.config.limit {
+		return nil
+	}
+
+	// wait for changes
+	for {
+		select {
+		case name := <-changes:
+			// start or extend the debounce window
+			if fire == nil {
+				deadline = time.Now().Add(c.config.debounce * maxDebounce)
+				c.Log.Printf("[Info] Detected %s change", name)
+			}
+			delay := c.config.debounce
+			if remaining := time.Until(deadline); remaining < delay {
+				delay = remaining
+			}
+			fire = time.After(delay)
+		case <-fire:
+			var dest string // written bundle location
+			// chain increments to the previous bundle
+			if !c.config.incremental {
+				previous = ""
+			}
+			if dest, err = c.runBackup(previous); err != nil {
+				c.Log.Printf("[Warning] %s", err.Error())
+				fire = time.After(retryInterval)
+				continue
+			}
+			fire, previous = nil, dest
+			if c.written++; c.config.limit > 0 && c.written >= c.config.limit {
+				return nil
+			}
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// runBackup writes a full backup to the destination or an increment
+// next to the destination when passed a previous bundle.  With increments
+// the full backup is also written next to the destination so a restart or
+// another instance taking over the lock starts a new chain instead of
+// replacing the base of an existing chain.
+func (c *Command) runBackup(previous string) (string, error) {
+	var dest string // bundle location
+	var status int  // backup exit status
+
+	// chained bundles never replace the destination
+	dest = c.config.fileName
+	if c.config.incremental {
+		dest = common.AppendSuffix(c.config.fileName, "."+time.Now().UTC().Format(suffixFormat))
+	}
+
+	// run backup
+	status = (&backup.Command{
+		Self:       c.Self,
+		Version:    c.Version,
+		AllowEmpty: true,
+		Log:        c.Log,
+	}).Run(c.backupArgs(dest, previous))
+
+	// check status
+	if status != 0 {
+		return "", fmt.Errorf("Failed to write backup to %s", dest)
+	}
+
+	// return location
+	return dest, nil
+}
+
+// poll runs a blocking query until stopped and reports index changes
+func (c *Command) poll(name string, fn watchFunc, changes chan<- string, stop <-chan struct{}) {
+	var index uint64 // last seen index
+
+	for {
+		var start time.Time     // query start
+		var last uint64         // returned index
+		var pause time.Duration // time to wait before the next query
+		var err error           // general error holder
+
+		// run query
+		start = time.Now()
+		last, err = fn(&api.QueryOptions{
+			WaitIndex: index,
+			WaitTime:  c.config.wait,
+		})
+
+		// check result
+		switch {
+		case err != nil:
+			c.Log.Printf("[Warning] Failed to watch %s: %s", name, err.Error())
+			pause = retryInterval
+		case index == 0:
+			// the first result sets the baseline
+			index = last
+		case last != index:
+			// any change including an index reset is reported
+			index = last
+			select {
+			case changes <- name:
+			case <-stop:
+				return
+			}
+		}
+
+		// endpoints without blocking support return at once
+		if elapsed := time.Since(start); pause == 0 && elapsed < minInterval {
+			pause = minInterval - elapsed
+		}
+
+		// wait before the next query
+		select {
+		case <-time.After(pause):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// watchers returns blocking queries for each endpoint of the requested sections
+func (c *Command) watchers() map[string]watchFunc {
+	var out map[string]watchFunc // output watchers
+
+	// init
+	out = make(map[string]watchFunc)
+
+	// keys
+	if c.config.sections[common.SectionKV] {
+		out["kv"] = func(opts *api.QueryOptions) (uint64, error) {
+			_, meta, err := c.consulClient.KV().Keys(c.config.consulPrefix, "", opts)
+			return lastIndex(meta, err)
+		}
+	}
+
+	// acl objects
+	if c.config.sections[common.SectionACLs] {
+		out["acl policy"] = func(opts *api.QueryOptions) (uint64, error) {
+			_, meta, err := c.consulClient.ACL().PolicyList(opts)
+			return lastIndex(meta, err)
+		}
+		out["acl role"] = func(opts *api.QueryOptions) (uint64, error) {
+			_, meta, err := c.consulClient.ACL().RoleList(opts)
+			return lastIndex(meta, err)
+		}
+		out["acl auth method"] = func(opts *api.QueryOptions) (uint64, error) {
+			_, meta, err := c.consulClient.ACL().AuthMethodList(opts)
+			return lastIndex(meta, err)
+		}
+		out["acl binding rule"] = func(opts *api.QueryOptions) (uint64, error) {
+			_, meta, err := c.consulClient.ACL().BindingRuleList("", opts)
+			return lastIndex(meta, err)
+		}
+		out["acl token"] = func(opts *api.QueryOptions) (uint64, error) {
+			_, meta, err := c.consulClient.ACL().TokenList(opts)
+			return lastIndex(meta, err)
+		}
+	}
+
+	// prepared queries
+	if c.config.sections[common.SectionQueries] {
+		out["query"] = func(opts *api.QueryOptions) (uint64, error) {
+			_, meta, err := c.consulClient.PreparedQuery().List(opts)
+			return lastIndex(meta, err)
+		}
+	}
+
+	// config entries of every kind
+	if c.config.sections[common.SectionConfigs] {
+		for _, kind := range configentry.Kinds {
+			kind := kind // loop variable captured below
+			out[kind] = func(opts *api.QueryOptions) (uint64, error) {
+				_, meta, err := c.consulClient.ConfigEntries().List(kind, opts)
+				return lastIndex(meta, err)
+			}
+		}
+	}
+
+	// intentions
+	if c.config.sections[common.SectionIntentions] {
+		out["intention"] = func(opts *api.QueryOptions) (uint64, error) {
+			_, meta, err := c.consulClient.Connect().Intentions(opts)
+			return lastIndex(meta, err)
+		}
+	}
+
+	// return watchers
+	return out
+}
+
+// lastIndex returns the index from query metadata
+func lastIndex(meta *api.QueryMeta, err error) (uint64, error) {
+	if err != nil {
+		return 0, err
+	}
+	return meta.LastIndex, nil
+}
@@ -6,8 +6,15 @@ import (
 
 	"github.com/mitchellh/cli"
 	"github.com/myENA/consul-backinator/command/backup"
+	"github.com/myENA/consul-backinator/command/diff"
 	"github.com/myENA/consul-backinator/command/dump"
+	"github.com/myENA/consul-backinator/command/list"
+	"github.com/myENA/consul-backinator/command/prune"
 	"github.com/myENA/consul-backinator/command/restore"
+	"github.com/myENA/consul-backinator/command/rollback"
+	"github.com/myENA/consul-backinator/command/schedule"
+	"github.com/myENA/consul-backinator/command/verify"
+	"github.com/myENA/consul-backinator/command/watch"
 )
 
 // package global logger
@@ -25,12 +32,40 @@ func init() {
 	cliCommands = map[string]cli.CommandFactory{
 		"backup": func() (cli.Command, error) {
 			return &backup.Command{
-				Self: os.Args[0],
-				Log:  logger,
+				Self:    os.Args[0],
+				Version: appVersion,
+				Log:     logger,
 			}, nil
 		},
 		"restore": func() (cli.Command, error) {
 			return &restore.Command{
+				Self:    os.Args[0],
+				Version: appVersion,
+				Log:     logger,
+			}, nil
+		},
+		"rollback": func() (cli.Command, error) {
+			return &rollback.Command{
+				Self:    os.Args[0],
+				Version: appVersion,
+				Log:     logger,
+			}, nil
+		},
+		"schedule": func() (cli.Command, error) {
+			return &schedule.Command{
+				Self:    os.Args[0],
+				Version: appVersion,
+				Log:     logger,
+			}, nil
+		},
+		"list": func() (cli.Command, error) {
+			return &list.Command{
+				Self: os.Args[0],
+				Log:  logger,
+			}, nil
+		},
+		"prune": func() (cli.Command, error) {
+			return &prune.Command{
 				Self: os.Args[0],
 				Log:  logger,
 			}, nil
@@ -41,5 +76,24 @@ func init() {
 				Log:  logger,
 			}, nil
 		},
+		"diff": func() (cli.Command, error) {
+			return &diff.Command{
+				Self: os.Args[0],
+				Log:  logger,
+			}, nil
+		},
+		"verify": func() (cli.Command, error) {
+			return &verify.Command{
+				Self: os.Args[0],
+				Log:  logger,
+			}, nil
+		},
+		"watch": func() (cli.Command, error) {
+			return &watch.Command{
+				Self:    os.Args[0],
+				Version: appVersion,
+				Log:     logger,
+			}, nil
+		},
 	}
 }
@@ -0,0 +1,207 @@
+package acl
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/consul/api"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+)
+
+// Well known identifiers of objects created by consul itself
+const (
+	GlobalManagementPolicyID = "00000000-0000-0000-0000-000000000001"
+	AnonymousTokenID         = "00000000-0000-0000-0000-000000000002"
+)
+
+// Token wraps an acl token with the legacy flag that is only returned
+// by the token listing endpoint and the legacy token type that is only
+// returned by the legacy endpoints
+type Token struct {
+	*api.ACLToken
+	Legacy bool   `json:",omitempty"`
+	Type   string `json:",omitempty"`
+}
+
+// Snapshot contains a complete copy of the acl system
+// including all objects referenced by tokens
+type Snapshot struct {
+	Policies     []*api.ACLPolicy
+	Roles        []*api.ACLRole
+	AuthMethods  []*api.ACLAuthMethod
+	BindingRules []*api.ACLBindingRule
+	Tokens       []*Token
+}
+
+// Fetch reads all acl objects from consul and returns a populated snapshot
+func Fetch(client *ccns.Client, opts *api.QueryOptions) (*Snapshot, error) {
+	var policies []*api.ACLPolicyListEntry    // policy listing
+	var methods []*api.ACLAuthMethodListEntry // auth method listing
+	var tokens []*api.ACLTokenListEntry       // token listing
+	var legacy bool                           // legacy tokens present
+	var snap *Snapshot                        // output snapshot
+	var err error                             // general error holder
+
+	// init snapshot
+	snap = new(Snapshot)
+
+	// list policies - the listing does not include rules
+	if policies, _, err = client.ACL().PolicyList(opts); err != nil {
+		return nil, err
+	}
+
+	// read full policies
+	for _, entry := range policies {
+		var policy *api.ACLPolicy // full policy
+		if policy, _, err = client.ACL().PolicyRead(entry.ID, opts); err != nil {
+			return nil, err
+		}
+		snap.Policies = append(snap.Policies, policy)
+	}
+
+	// roles are returned in full
+	if snap.Roles, _, err = client.ACL().RoleList(opts); err != nil {
+		return nil, err
+	}
+
+	// list auth methods - the listing does not include configuration
+	if methods, _, err = client.ACL().AuthMethodList(opts); err != nil {
+		return nil, err
+	}
+
+	// read full auth methods
+	for _, entry := range methods {
+		var method *api.ACLAuthMethod // full method
+		if method, _, err = client.ACL().AuthMethodRead(entry.Name, opts); err != nil {
+			return nil, err
+		}
+		// skip methods removed since listing
+		if method != nil {
+			snap.AuthMethods = append(snap.AuthMethods, method)
+		}
+	}
+
+	// binding rules for all methods are returned in full
+	if snap.BindingRules, _, err = client.ACL().BindingRuleList("", opts); err != nil {
+		return nil, err
+	}
+
+	// list tokens - the listing does not include secrets
+	if tokens, _, err = client.ACL().TokenList(opts); err != nil {
+		return nil, err
+	}
+
+	// read full tokens
+	for _, entry := range tokens {
+		var token *api.ACLToken // full token
+		// legacy tokens are assigned an accessor by the leader after creation
+		if entry.AccessorID == "" {
+			legacy = true
+			continue
+		}
+		if token, _, err = client.ACL().TokenRead(entry.AccessorID, opts); err != nil {
+			return nil, err
+		}
+		snap.Tokens = append(snap.Tokens, &Token{
+			ACLToken: token,
+			Legacy:   entry.Legacy,
+		})
+		legacy = legacy || entry.Legacy
+	}
+
+	// read legacy token types and tokens still waiting for an accessor
+	if legacy {
+		if err = fetchLegacy(client, opts, snap); err != nil {
+			return nil, err
+		}
+	}
+
+	// all good
+	return snap, nil
+}
+
+// fetchLegacy records the legacy type of fetched legacy tokens and adds
+// legacy tokens that have not yet been assigned an accessor.  Both are
+// only available from the legacy endpoints.  Pending tokens are restored
+// by secret and are assigned a new accessor by the target cluster.
+func fetchLegacy(client *ccns.Client, opts *api.QueryOptions, snap *Snapshot) error {
+	var entries []*api.ACLEntry   // legacy tokens
+	var secrets map[string]*Token // already fetched tokens
+	var err error                 // general error holder
+
+	// list legacy tokens
+	if entries, _, err = client.ACL().List(opts); err != nil {
+		return err
+	}
+
+	// index fetched tokens
+	secrets = make(map[string]*Token, len(snap.Tokens))
+	for _, token := range snap.Tokens {
+		secrets[token.SecretID] = token
+	}
+
+	// record types and add missing tokens
+	for _, entry := range entries {
+		if token, ok := secrets[entry.ID]; ok {
+			token.Type = entry.Type
+			continue
+		}
+		snap.Tokens = append(snap.Tokens, &Token{
+			ACLToken: &api.ACLToken{
+				SecretID:    entry.ID,
+				Description: entry.Name,
+				Rules:       entry.Rules,
+			},
+			Legacy: true,
+			Type:   entry.Type,
+		})
+	}
+
+	// all good
+	return nil
+}
+
+// Count returns the total number of objects contained in the snapshot
+func (s *Snapshot) Count() int {
+	return len(s.Policies) + len(s.Roles) + len(s.AuthMethods) +
+		len(s.BindingRules) + len(s.Tokens)
+}
+
+// Decode decodes acl backup data.  Backups written before snapshot support
+// contain a list of legacy acl entries which are returned in place of a snapshot.
+func Decode(data []byte) (*Snapshot, []*api.ACLEntry, error) {
+	var snap *Snapshot         // decoded snapshot
+	var legacy []*api.ACLEntry // decoded legacy entries
+	var err error              // general error holder
+
+	// legacy backups are a json array
+	if isArray(data) {
+		if err = json.Unmarshal(data, &legacy); err != nil {
+			return nil, nil, err
+		}
+		return nil, legacy, nil
+	}
+
+	// init and decode snapshot
+	snap = new(Snapshot)
+	if err = json.Unmarshal(data, snap); err != nil {
+		return nil, nil, err
+	}
+
+	// all good
+	return snap, nil, nil
+}
+
+// isArray checks if the first non-space character is an opening bracket
+func isArray(data []byte) bool {
+	for _, b := range data {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
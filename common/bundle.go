@@ -0,0 +1,343 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bundle section names
+const (
+	SectionKV         = "kv"
+	SectionACLs       = "acls"
+	SectionQueries    = "queries"
+	SectionConfigs    = "configs"
+	SectionIntentions = "intentions"
+)
+
+// Sections contains all known bundle section names in restore order
+var Sections = []string{
+	SectionKV,
+	SectionACLs,
+	SectionQueries,
+	SectionConfigs,
+	SectionIntentions,
+}
+
+// manifestName is the name of the manifest within a bundle archive
+const manifestName = "manifest.json"
+
+// Exported bundle errors
+var (
+	ErrMissingManifest = errors.New("bundle does not contain a manifest")
+	ErrMissingSection  = errors.New("bundle does not contain the requested section")
+	ErrBadSectionHash  = errors.New("bundle section hash does not match manifest")
+)
+
+// Manifest describes the source and contents of a bundle
+type Manifest struct {
+	Version    string
+	Datacenter string
+	Leader     string
+	Index      uint64
+	Timestamp  time.Time
+	Prefix     string
+	Signer     string
+	Since      uint64 `json:",omitempty"`
+	Parent     string `json:",omitempty"`
+	Sections   map[string]*SectionInfo
+}
+
+// SectionInfo describes a single section within a bundle
+type SectionInfo struct {
+	Count int
+	Size  int
+	Hash  string
+}
+
+// Bundle contains multiple named sections and a manifest
+// that is written as a single backup object
+type Bundle struct {
+	Manifest *Manifest
+	sections map[string][]byte
+}
+
+// NewBundle returns an empty bundle using the passed manifest
+func NewBundle(m *Manifest) *Bundle {
+	// init section info
+	if m.Sections == nil {
+		m.Sections = make(map[string]*SectionInfo)
+	}
+	return &Bundle{
+		Manifest: m,
+		sections: make(map[string][]byte),
+	}
+}
+
+// Add adds a named section and records its details in the manifest
+func (b *Bundle) Add(name string, count int, data []byte) {
+	b.sections[name] = data
+	b.Manifest.Sections[name] = &SectionInfo{
+		Count: count,
+		Size:  len(data),
+		Hash:  hashSection(data),
+	}
+}
+
+// Has checks if the named section is present in the bundle
+func (b *Bundle) Has(name string) bool {
+	_, ok := b.sections[name]
+	return ok
+}
+
+// Names returns the names of all sections present in the bundle
+func (b *Bundle) Names() []string {
+	var names []string // section names
+	for name := range b.sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Section returns the named section after validating its hash
+func (b *Bundle) Section(name string) ([]byte, error) {
+	var data []byte       // section data
+	var info *SectionInfo // manifest info
+	var ok bool           // presence check
+
+	// get section data and info
+	if data, ok = b.sections[name]; !ok {
+		return nil, ErrMissingSection
+	}
+	if info, ok = b.Manifest.Sections[name]; !ok {
+		return nil, ErrMissingSection
+	}
+
+	// validate hash
+	if hashSection(data) != info.Hash {
+		return nil, ErrBadSectionHash
+	}
+
+	// all good
+	return data, nil
+}
+
+// Encode encodes the bundle as a tar archive with the manifest first
+func (b *Bundle) Encode() ([]byte, error) {
+	var buf *bytes.Buffer // output buffer
+	var tw *tar.Writer    // archive writer
+	var manifest []byte   // encoded manifest
+	var err error         // general error holder
+
+	// encode manifest
+	if manifest, err = json.MarshalIndent(b.Manifest, "", "  "); err != nil {
+		return nil, err
+	}
+
+	// init archive
+	buf = new(bytes.Buffer)
+	tw = tar.NewWriter(buf)
+
+	// write manifest
+	if err = writeTarFile(tw, manifestName, b.Manifest.Timestamp, manifest); err != nil {
+		return nil, err
+	}
+
+	// write sections
+	for _, name := range b.Names() {
+		if err = writeTarFile(tw, name+".json", b.Manifest.Timestamp, b.sections[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	// finish archive
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+
+	// all good
+	return buf.Bytes(), nil
+}
+
+// DecodeBundle decodes a bundle archive
+func DecodeBundle(data []byte) (*Bundle, error) {
+	var tr *tar.Reader    // archive reader
+	var hdr *tar.Header   // archive entry header
+	var bundle *Bundle    // output bundle
+	var manifest Manifest // decoded manifest
+	var err error         // general error holder
+
+	// init reader
+	tr = tar.NewReader(bytes.NewReader(data))
+
+	// the manifest is always first
+	if hdr, err = tr.Next(); err != nil || hdr.Name != manifestName {
+		return nil, ErrMissingManifest
+	}
+
+	// decode manifest
+	if err = json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, err
+	}
+
+	// init bundle
+	bundle = NewBundle(&manifest)
+
+	// read sections
+	for {
+		var section []byte // section data
+		if hdr, err = tr.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if section, err = ioutil.ReadAll(tr); err != nil {
+			return nil, err
+		}
+		bundle.sections[strings.TrimSuffix(hdr.Name, ".json")] = section
+	}
+
+	// all good
+	return bundle, nil
+}
+
+// IsBundle checks if decoded backup data is a bundle archive
+func IsBundle(data []byte) bool {
+	hdr, err := tar.NewReader(bytes.NewReader(data)).Next()
+	return err == nil && hdr.Name == manifestName
+}
+
+// ParseSections parses a comma separated list of section names.
+// The special name "all" selects every known section.
+func ParseSections(str string) (map[string]bool, error) {
+	var sections map[string]bool // selected sections
+
+	// init output
+	sections = make(map[string]bool)
+
+	// loop through names
+	for _, name := range strings.Split(str, ",") {
+		// clean name
+		name = strings.TrimSpace(name)
+		// check name
+		switch {
+		case name == "":
+			continue
+		case name == "all":
+			for _, s := range Sections {
+				sections[s] = true
+			}
+		case isSection(name):
+			sections[name] = true
+		default:
+			return nil, fmt.Errorf("unknown bundle section: %s", name)
+		}
+	}
+
+	// all good
+	return sections, nil
+}
+
+// WriteBundle writes an encrypted/compressed bundle and signature
+// to a local file, s3 datastore or stdout
+func WriteBundle(dest, sigDest string, keys *Keys, b *Bundle) error {
+	var data []byte // encoded bundle
+	var err error   // general error holder
+
+	// encode bundle
+	if data, err = b.Encode(); err != nil {
+		return err
+	}
+
+	// write bundle
+	return WriteData(dest, sigDest, keys, data)
+}
+
+// ReadBundle reads an encrypted/compressed bundle from a local
+// file, s3 datastore or stdin and validates checksums
+func ReadBundle(src, sigSrc string, keys *Keys) (*Bundle, error) {
+	var data []byte // decoded bundle
+	var err error   // general error holder
+
+	// read data
+	if data, err = ReadData(src, sigSrc, keys); err != nil {
+		return nil, err
+	}
+
+	// decode and return bundle
+	return DecodeBundle(data)
+}
+
+// isSection checks if the passed name is a known section
+func isSection(name string) bool {
+	for _, s := range Sections {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hashSection returns the hex encoded sha256 sum of section data
+func hashSection(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeTarFile writes a single file to a tar archive
+func writeTarFile(tw *tar.Writer, name string, mtime time.Time, data []byte) error {
+	var err error // general error holder
+
+	// write header
+	if err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: mtime,
+	}); err != nil {
+		return err
+	}
+
+	// write data
+	_, err = tw.Write(data)
+
+	// return write error
+	return err
+}
+
+// ReadSection reads an encrypted/compressed file or S3 datastore object
+// and returns the named section when the object is a bundle or the
+// entire object otherwise
+func ReadSection(src, sigSrc string, keys *Keys, name string) ([]byte, error) {
+	var data []byte    // decoded data
+	var bundle *Bundle // decoded bundle
+	var err error      // general error holder
+
+	// read data
+	if data, err = ReadData(src, sigSrc, keys); err != nil {
+		return nil, err
+	}
+
+	// return data as is when not a bundle
+	if !IsBundle(data) {
+		return data, nil
+	}
+
+	// decode bundle
+	if bundle, err = DecodeBundle(data); err != nil {
+		return nil, err
+	}
+
+	// return section
+	return bundle.Section(name)
+}
@@ -8,7 +8,6 @@ import (
 	"errors"
 	"hash"
 	"io"
-	"os"
 )
 
 // ErrBadSignature indicates failed signature validation
@@ -21,12 +20,21 @@ func hashKey(key string) []byte {
 	return sum[:]
 }
 
-// writeChecksum writes a signature to the given io.Writer
-func writeChecksum(out io.Writer, key string, data []byte) error {
+// writeChecksum writes a signature to the given io.Writer.  The encrypted data
+// is signed when a signing key is present so the signature may be validated
+// without the ability to decrypt.  Otherwise an hmac of the data is written,
+// which only proves integrity when the passphrase is a secret.
+func writeChecksum(out io.Writer, keys *Keys, raw, data []byte) error {
 	var encoder io.WriteCloser // encoding writer
 	var sig hash.Hash          // hash object
 	var err error              // general error handler
 
+	// check for signing key
+	if keys.Signer != nil {
+		_, err = out.Write(signRaw(keys.Signer, raw))
+		return err
+	}
+
 	// init encoder
 	encoder = base64.NewEncoder(base64.StdEncoding, out)
 
@@ -34,7 +42,7 @@ func writeChecksum(out io.Writer, key string, data []byte) error {
 	defer encoder.Close()
 
 	// build hmac object
-	sig = hmac.New(sha256.New, hashKey(key))
+	sig = hmac.New(sha256.New, hashKey(keys.Passphrase))
 
 	// compute hash
 	sig.Write(data)
@@ -46,25 +54,7 @@ func writeChecksum(out io.Writer, key string, data []byte) error {
 	return err
 }
 
-// writeFileChecksum writes a signature to a file
-func writeFileChecksum(fname, key string, data []byte) error {
-	var out *os.File // destination file
-	var err error    // general error handler
-
-	// open destination file and create/overwite if neeeded
-	// and ensure it's only accessible by the current executer
-	if out, err = os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
-		return err
-	}
-
-	// close when done
-	defer out.Close()
-
-	// return write error
-	return writeChecksum(out, key, data)
-}
-
-// validateChecksum
+// validateChecksum validates an hmac signature of the data
 func validateChecksum(in io.Reader, key string, data []byte) error {
 	var decoder io.Reader // encoding writer
 	var sig hash.Hash     // hash object
@@ -97,19 +87,34 @@ func validateChecksum(in io.Reader, key string, data []byte) error {
 	return nil
 }
 
-// validateFileChecksum validates a signature file and data
-func validateFileChecksum(fname, key string, data []byte) error {
-	var in *os.File // input file
-	var err error   // general error handler
+// readSigned validates the signature and decodes encrypted/compressed data.
+// Public key signatures are validated before decryption.
+func readSigned(raw, sig []byte, keys *Keys) ([]byte, error) {
+	var outBytes []byte // output buffer
+	var err error       // general error handler
+
+	// check signature type
+	if isPublicSignature(sig) {
+		// validate encrypted data
+		if err = verifyRaw(keys.Verifier, raw, sig); err != nil {
+			return nil, err
+		}
+		// decode and return
+		return readBytes(bytes.NewReader(raw), keys)
+	} else if keys.Verifier != nil {
+		return nil, ErrNotPublicSignature
+	}
 
-	// open source file
-	if in, err = os.Open(fname); err != nil {
-		return err
+	// read and decode bytes
+	if outBytes, err = readBytes(bytes.NewReader(raw), keys); err != nil {
+		return nil, err
 	}
 
-	// close when done
-	defer in.Close()
+	// validate signature
+	if err = validateChecksum(bytes.NewReader(sig), keys.Passphrase, outBytes); err != nil {
+		return nil, err
+	}
 
-	// return validation
-	return validateChecksum(in, key, data)
+	// return bytes and last error state
+	return outBytes, err
 }
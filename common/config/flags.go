@@ -5,12 +5,26 @@ import (
 	"flag"
 
 	"github.com/hashicorp/consul/api"
+	"github.com/myENA/consul-backinator/common"
 	ccns "github.com/myENA/consul-backinator/common/consul"
 )
 
 // ErrUnknownArg is returned when non-flag arguments are present after the command
 var ErrUnknownArg = errors.New("Unknown non-flag argument(s) present after command")
 
+// ErrStdioOption is returned when an option other than 'file' is set to '-'
+var ErrStdioOption = errors.New("Only the 'file' option may be '-' to use stdin or stdout")
+
+// CheckStdio returns an error when any of the passed option values is '-'
+func CheckStdio(values ...string) error {
+	for _, v := range values {
+		if common.IsStdio(v) {
+			return ErrStdioOption
+		}
+	}
+	return nil
+}
+
 // AddSharedConsulFlags adds flags shared by multiple command implementations
 func AddSharedConsulFlags(cmdFlags *flag.FlagSet, consulConfig *ccns.Config) {
 	// client flags
@@ -36,3 +50,32 @@ func AddSharedConsulFlags(cmdFlags *flag.FlagSet, consulConfig *ccns.Config) {
 	cmdFlags.BoolVar(&consulConfig.TLS.InsecureSkipVerify, "tls-skip-verify", false,
 		"Optional bool for verifying a TLS certificate (not recommended)")
 }
+
+// SharedConsulArgs returns command line arguments matching the
+// shared flags set in the passed config for use with another command
+func SharedConsulArgs(consulConfig *ccns.Config) []string {
+	var args []string // output arguments
+
+	// add set string flags
+	for _, f := range []struct{ name, value string }{
+		{"addr", consulConfig.Address},
+		{"scheme", consulConfig.Scheme},
+		{"dc", consulConfig.Datacenter},
+		{"token", consulConfig.Token},
+		{"ca-cert", consulConfig.TLS.CAFile},
+		{"client-cert", consulConfig.TLS.CertFile},
+		{"client-key", consulConfig.TLS.KeyFile},
+	} {
+		if f.value != "" {
+			args = append(args, "-"+f.name, f.value)
+		}
+	}
+
+	// add bool flags
+	if consulConfig.TLS.InsecureSkipVerify {
+		args = append(args, "-tls-skip-verify")
+	}
+
+	// return arguments
+	return args
+}
@@ -0,0 +1,92 @@
+package configentry
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hashicorp/consul/api"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+)
+
+// Kinds contains all supported config entry kinds in dependency order.
+// Defaults must exist before the discovery chain entries that validate
+// against them and resolvers must exist before routers and splitters
+// that reference their subsets.  Intentions are stored as config entries
+// on newer clusters but are handled separately with the intention backup.
+var Kinds = []string{
+	api.ProxyDefaults,
+	api.ServiceDefaults,
+	api.ServiceResolver,
+	api.ServiceSplitter,
+	api.ServiceRouter,
+	api.IngressGateway,
+	api.TerminatingGateway,
+}
+
+// Fetch reads config entries of every supported kind from consul
+func Fetch(client *ccns.Client, opts *api.QueryOptions) ([]api.ConfigEntry, error) {
+	var entries []api.ConfigEntry // all entries
+	var err error                 // general error holder
+
+	// loop through kinds in order
+	for _, kind := range Kinds {
+		var kindEntries []api.ConfigEntry // entries of this kind
+		if kindEntries, _, err = client.ConfigEntries().List(kind, opts); err != nil {
+			return nil, err
+		}
+		entries = append(entries, kindEntries...)
+	}
+
+	// all good
+	return entries, nil
+}
+
+// Decode decodes a list of config entries of mixed kinds
+func Decode(data []byte) ([]api.ConfigEntry, error) {
+	var raw []json.RawMessage     // undecoded entries
+	var entries []api.ConfigEntry // decoded entries
+	var err error                 // general error holder
+
+	// split entries
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	// decode each entry into the type matching its kind
+	for _, r := range raw {
+		var header struct{ Kind string } // entry kind
+		var entry api.ConfigEntry        // typed entry
+		if err = json.Unmarshal(r, &header); err != nil {
+			return nil, err
+		}
+		if entry, err = api.MakeConfigEntry(header.Kind, ""); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(r, entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	// all good
+	return entries, nil
+}
+
+// Sort orders entries by kind in dependency order and then by name
+func Sort(entries []api.ConfigEntry) {
+	var order map[string]int // kind positions
+
+	// index kinds
+	order = make(map[string]int, len(Kinds))
+	for i, kind := range Kinds {
+		order[kind] = i + 1
+	}
+
+	// sort entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		if ki, kj := order[entries[i].GetKind()], order[entries[j].GetKind()]; ki != kj {
+			return ki < kj
+		}
+		return entries[i].GetName() < entries[j].GetName()
+	})
+}
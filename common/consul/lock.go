@@ -0,0 +1,88 @@
+package consul
+
+import (
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Lock session settings
+const (
+	lockSessionName = "consul-backinator"
+	lockSessionTTL  = "15s"
+	lockRetry       = 5 * time.Second
+)
+
+// RunLocked calls fn while holding a session lock on key so only one process
+// sharing the key runs fn at a time.  The channel passed to fn is closed when
+// stop is closed or the lock is lost and fn should return promptly.  A lost
+// lock is requested again so another process may take over when the holder
+// disappears.  RunLocked returns when fn returns while holding the lock or
+// stop is closed.
+func (c *Client) RunLocked(key string, stop <-chan struct{}, fn func(done <-chan struct{}) error) error {
+	for {
+		var lock *api.Lock         // session lock
+		var lost <-chan struct{}   // closed when the lock is lost
+		var done chan struct{}     // closed when fn should return
+		var finished chan struct{} // closed when fn returned
+		var err error              // general error holder
+
+		// build lock
+		if lock, err = c.LockOpts(&api.LockOptions{
+			Key:         key,
+			SessionName: lockSessionName,
+			SessionTTL:  lockSessionTTL,
+		}); err != nil {
+			return err
+		}
+
+		// wait for the lock
+		logger.Printf("[Info] Waiting for lock %s", key)
+		if lost, err = lock.Lock(stop); err != nil {
+			logger.Printf("[Warning] Failed to acquire lock %s: %s", key, err.Error())
+			select {
+			case <-time.After(lockRetry):
+				continue
+			case <-stop:
+				return nil
+			}
+		}
+		if lost == nil {
+			// stopped while waiting
+			return nil
+		}
+		logger.Printf("[Info] Acquired lock %s", key)
+
+		// run until stopped or the lock is lost
+		done = make(chan struct{})
+		finished = make(chan struct{})
+		go func() {
+			defer close(done)
+			select {
+			case <-stop:
+			case <-lost:
+			case <-finished:
+			}
+		}()
+		err = fn(done)
+		close(finished)
+
+		// check for a lost lock before releasing it
+		select {
+		case <-lost:
+		default:
+			lock.Unlock()
+			return err
+		}
+		lock.Unlock()
+		select {
+		case <-stop:
+			return err
+		default:
+		}
+		if err != nil {
+			return err
+		}
+		logger.Printf("[Warning] Lost lock %s", key)
+	}
+}
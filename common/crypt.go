@@ -0,0 +1,128 @@
+package common
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Backup files written with authenticated encryption start with a fixed
+// header containing the format version, key derivation salt and nonce.
+// Files without this header are read as the legacy unauthenticated format.
+const (
+	headerMagic   = "CBAK" // file signature
+	formatVersion = 2      // current format version
+	saltSize      = 16     // key derivation salt length
+	nonceSize     = 12     // aes-gcm nonce length
+	headerSize    = len(headerMagic) + 1 + saltSize + nonceSize
+	scryptN       = 1 << 15 // scrypt cost parameter
+	scryptR       = 8       // scrypt block size parameter
+	scryptP       = 1       // scrypt parallelization parameter
+	keySize       = 32      // derived key length (aes-256)
+)
+
+// Exported encryption errors
+var (
+	ErrUnknownFormat = errors.New("Unsupported backup format version")
+	ErrDecryptFailed = errors.New("Decryption failed.  " +
+		"Please check your key and backup file.")
+)
+
+// deriveKey derives an encryption key from a passphrase and salt
+func deriveKey(key string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(key), salt, scryptN, scryptR, scryptP, keySize)
+}
+
+// newGCM returns an aes-gcm cipher using a key derived from the passphrase and salt
+func newGCM(key string, salt []byte) (cipher.AEAD, error) {
+	var dk []byte       // derived key
+	var cb cipher.Block // cipher block interface
+	var err error       // general error holder
+
+	// derive key
+	if dk, err = deriveKey(key, salt); err != nil {
+		return nil, err
+	}
+
+	// init cipher block
+	if cb, err = aes.NewCipher(dk); err != nil {
+		return nil, err
+	}
+
+	// return aead
+	return cipher.NewGCM(cb)
+}
+
+// seal encrypts and authenticates plaintext and returns the header and ciphertext
+func seal(key string, plaintext []byte) ([]byte, error) {
+	var header []byte    // file header
+	var aead cipher.AEAD // aead cipher
+	var err error        // general error holder
+
+	// build header with random salt and nonce
+	header = make([]byte, headerSize)
+	copy(header, headerMagic)
+	header[len(headerMagic)] = formatVersion
+	if _, err = io.ReadFull(rand.Reader, header[len(headerMagic)+1:]); err != nil {
+		return nil, err
+	}
+
+	// init cipher
+	if aead, err = newGCM(key, saltOf(header)); err != nil {
+		return nil, err
+	}
+
+	// encrypt appending to header - the header is authenticated as well
+	return aead.Seal(header, nonceOf(header), plaintext, header), nil
+}
+
+// open validates and decrypts data written by seal
+func open(key string, data []byte) ([]byte, error) {
+	var header []byte    // file header
+	var aead cipher.AEAD // aead cipher
+	var out []byte       // decrypted data
+	var err error        // general error holder
+
+	// check length and version
+	if len(data) < headerSize {
+		return nil, ErrDecryptFailed
+	}
+	if header = data[:headerSize]; header[len(headerMagic)] != formatVersion {
+		return nil, ErrUnknownFormat
+	}
+
+	// init cipher
+	if aead, err = newGCM(key, saltOf(header)); err != nil {
+		return nil, err
+	}
+
+	// decrypt and authenticate
+	if out, err = aead.Open(nil, nonceOf(header), data[headerSize:], header); err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	// all good
+	return out, nil
+}
+
+// isSealed checks if data starts with the authenticated format header
+func isSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(headerMagic))
+}
+
+// saltOf returns the salt portion of a header
+func saltOf(header []byte) []byte {
+	start := len(headerMagic) + 1
+	return header[start : start+saltSize]
+}
+
+// nonceOf returns the nonce portion of a header
+func nonceOf(header []byte) []byte {
+	start := len(headerMagic) + 1 + saltSize
+	return header[start : start+nonceSize]
+}
@@ -0,0 +1,97 @@
+package intention
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	ccns "github.com/myENA/consul-backinator/common/consul"
+)
+
+// defaultNamespace is the only namespace available outside consul enterprise
+const defaultNamespace = "default"
+
+// unsupportedErrors are returned by clusters without intention config
+// entries or without the config entry endpoints
+var unsupportedErrors = []string{
+	"invalid config entry kind",
+	"Unexpected response code: 404",
+}
+
+// Snapshot contains all intentions as returned by the legacy intentions
+// endpoint along with the service-intentions config entries that store
+// them on consul 1.9 and later.  The config entries include layer 7
+// permissions that can not be written with the legacy endpoints.
+type Snapshot struct {
+	Intentions    []*api.Intention
+	ConfigEntries []*api.ServiceIntentionsConfigEntry
+}
+
+// Fetch reads all intentions from consul and returns a populated snapshot
+func Fetch(client *ccns.Client, opts *api.QueryOptions) (*Snapshot, error) {
+	var entries []api.ConfigEntry // intention config entries
+	var snap *Snapshot            // output snapshot
+	var err error                 // general error holder
+
+	// init snapshot
+	snap = new(Snapshot)
+
+	// get all intentions
+	if snap.Intentions, _, err = client.Connect().Intentions(opts); err != nil {
+		return nil, err
+	}
+
+	// older clusters do not support intention config entries
+	// and will only be restored with the legacy endpoints
+	if entries, _, err = client.ConfigEntries().List(api.ServiceIntentions, opts); err != nil {
+		if isUnsupported(err) {
+			return snap, nil
+		}
+		return nil, err
+	}
+
+	// add config entries
+	for _, entry := range entries {
+		if sie, ok := entry.(*api.ServiceIntentionsConfigEntry); ok {
+			snap.ConfigEntries = append(snap.ConfigEntries, sie)
+		}
+	}
+
+	// all good
+	return snap, nil
+}
+
+// Decode decodes intention backup data
+func Decode(data []byte) (*Snapshot, error) {
+	var snap *Snapshot // decoded snapshot
+	var err error      // general error holder
+
+	// init and decode snapshot
+	snap = new(Snapshot)
+	if err = json.Unmarshal(data, snap); err != nil {
+		return nil, err
+	}
+
+	// all good
+	return snap, nil
+}
+
+// Name returns a service name qualified with a namespace when one other than
+// the default is set.  This is the format accepted by the exact intention endpoints.
+func Name(ns, name string) string {
+	if ns == "" || ns == defaultNamespace {
+		return name
+	}
+	return ns + "/" + name
+}
+
+// isUnsupported checks if an error was caused by a cluster
+// that does not support intention config entries
+func isUnsupported(err error) bool {
+	for _, msg := range unsupportedErrors {
+		if strings.Contains(err.Error(), msg) {
+			return true
+		}
+	}
+	return false
+}
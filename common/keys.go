@@ -0,0 +1,183 @@
+package common
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// ageHeader is the leading line of every age encrypted file
+const ageHeader = "age-encryption.org/"
+
+// ErrMissingIdentity is returned when reading public key
+// encrypted data without a private key identity
+var ErrMissingIdentity = errors.New("Backup is encrypted to public key recipients.  " +
+	"Please pass an identity file containing a matching private key.")
+
+// ErrIdentityRecipient is returned when the public key of an identity can not be derived
+var ErrIdentityRecipient = errors.New("Public key can not be derived from identity.  " +
+	"Only X25519 identities are supported.")
+
+// Keys contains the passphrase and optional public key recipients, private
+// key identities and signing keys used to encrypt, decrypt and sign backup data.
+// Data is encrypted to the recipients when present instead of the passphrase
+// and signed with the signing key when present instead of the passphrase.
+type Keys struct {
+	Passphrase string
+	Recipients []age.Recipient
+	Identities []age.Identity
+	Signer     ed25519.PrivateKey
+	Verifier   ed25519.PublicKey
+}
+
+// NewKeys returns passphrase only keys
+func NewKeys(passphrase string) *Keys {
+	return &Keys{Passphrase: passphrase}
+}
+
+// AddRecipients parses a comma separated list of age public keys or
+// paths to files containing public keys and adds them to the recipients
+func (k *Keys) AddRecipients(str string) error {
+	var recipients []age.Recipient // parsed recipients
+	var err error                  // general error holder
+
+	// loop through list
+	for _, r := range strings.Split(str, ",") {
+		// clean entry
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		// check for public key or file
+		if strings.HasPrefix(r, "age1") {
+			recipients, err = age.ParseRecipients(strings.NewReader(r))
+		} else {
+			recipients, err = readRecipients(r)
+		}
+		if err != nil {
+			return err
+		}
+		// add recipients
+		k.Recipients = append(k.Recipients, recipients...)
+	}
+
+	// all good
+	return nil
+}
+
+// AddIdentities reads private key identities from a file
+func (k *Keys) AddIdentities(fname string) error {
+	var identities []age.Identity // parsed identities
+	var in *os.File               // input file
+	var err error                 // general error holder
+
+	// open source file
+	if in, err = os.Open(fname); err != nil {
+		return err
+	}
+
+	// close when done
+	defer in.Close()
+
+	// parse identities
+	if identities, err = age.ParseIdentities(in); err != nil {
+		return err
+	}
+
+	// add identities
+	k.Identities = append(k.Identities, identities...)
+
+	// all good
+	return nil
+}
+
+// IdentityRecipients returns the public keys of the private key identities
+// so data can be encrypted to the holders of those identities
+func (k *Keys) IdentityRecipients() ([]string, error) {
+	var out []string // public keys
+
+	// loop through identities
+	for _, identity := range k.Identities {
+		var x *age.X25519Identity // x25519 identity
+		var ok bool               // type check
+		if x, ok = identity.(*age.X25519Identity); !ok {
+			return nil, ErrIdentityRecipient
+		}
+		out = append(out, x.Recipient().String())
+	}
+
+	// return public keys
+	return out, nil
+}
+
+// readRecipients reads public key recipients from a file
+func readRecipients(fname string) ([]age.Recipient, error) {
+	var in *os.File // input file
+	var err error   // general error holder
+
+	// open source file
+	if in, err = os.Open(fname); err != nil {
+		return nil, err
+	}
+
+	// close when done
+	defer in.Close()
+
+	// parse and return recipients
+	return age.ParseRecipients(in)
+}
+
+// encryptTo encrypts data to public key recipients
+func encryptTo(recipients []age.Recipient, data []byte) ([]byte, error) {
+	var buf *bytes.Buffer  // output buffer
+	var enc io.WriteCloser // encrypted writer
+	var err error          // general error holder
+
+	// init buffer and encrypted writer
+	buf = new(bytes.Buffer)
+	if enc, err = age.Encrypt(buf, recipients...); err != nil {
+		return nil, err
+	}
+
+	// write data
+	if _, err = enc.Write(data); err != nil {
+		return nil, err
+	}
+
+	// flush and authenticate
+	if err = enc.Close(); err != nil {
+		return nil, err
+	}
+
+	// all good
+	return buf.Bytes(), nil
+}
+
+// decryptWith decrypts public key encrypted data with private key identities
+func decryptWith(identities []age.Identity, data []byte) ([]byte, error) {
+	var dec io.Reader // decrypted reader
+	var err error     // general error holder
+
+	// check identities
+	if len(identities) == 0 {
+		return nil, ErrMissingIdentity
+	}
+
+	// init decrypted reader
+	if dec, err = age.Decrypt(bytes.NewReader(data), identities...); err != nil {
+		return nil, err
+	}
+
+	// read and return data
+	return ioutil.ReadAll(dec)
+}
+
+// isEncryptedTo checks if data was encrypted to public key recipients
+func isEncryptedTo(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ageHeader))
+}
@@ -0,0 +1,132 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Exported increment errors
+var (
+	ErrIncrement = errors.New("Data is an incremental backup and must be " +
+		"restored on top of a base backup")
+	ErrNotIncrement = errors.New("Data is not an incremental backup")
+)
+
+// Increment contains the keys changed after a previous backup was taken.
+// The full list of keys present at the time is kept so the next increment
+// is able to find deleted keys without replaying the chain.
+type Increment struct {
+	Since   uint64
+	Keys    []string
+	Pairs   api.KVPairs
+	Deleted []string
+}
+
+// NewIncrement builds an increment from the current pairs using the keys
+// and index of the previous backup
+func NewIncrement(kvps api.KVPairs, previous []string, since uint64) *Increment {
+	var current map[string]bool // current keys
+	var inc *Increment          // output increment
+
+	// init
+	current = make(map[string]bool, len(kvps))
+	inc = &Increment{Since: since}
+
+	// keep pairs modified after the previous backup
+	for _, kv := range kvps {
+		current[kv.Key] = true
+		inc.Keys = append(inc.Keys, kv.Key)
+		if kv.ModifyIndex > since {
+			inc.Pairs = append(inc.Pairs, kv)
+		}
+	}
+
+	// find tombstones
+	for _, key := range previous {
+		if !current[key] {
+			inc.Deleted = append(inc.Deleted, key)
+		}
+	}
+
+	// sort output
+	sort.Strings(inc.Keys)
+	sort.Strings(inc.Deleted)
+
+	// return increment
+	return inc
+}
+
+// Count returns the number of changed and deleted keys
+func (i *Increment) Count() int {
+	return len(i.Pairs) + len(i.Deleted)
+}
+
+// Apply replays the increment on top of the passed pairs
+// and returns the resulting pairs ordered by key
+func (i *Increment) Apply(kvps api.KVPairs) api.KVPairs {
+	var pairs map[string]*api.KVPair // pairs by key
+	var out api.KVPairs              // output pairs
+
+	// index pairs
+	pairs = make(map[string]*api.KVPair, len(kvps))
+	for _, kv := range kvps {
+		pairs[kv.Key] = kv
+	}
+
+	// apply changes and tombstones
+	for _, kv := range i.Pairs {
+		pairs[kv.Key] = kv
+	}
+	for _, key := range i.Deleted {
+		delete(pairs, key)
+	}
+
+	// collect and sort output
+	for _, kv := range pairs {
+		out = append(out, kv)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		return out[a].Key < out[b].Key
+	})
+
+	// return pairs
+	return out
+}
+
+// IsIncrement checks if kv backup data contains an increment
+// rather than the array of pairs written by a full backup
+func IsIncrement(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// DecodeIncrement decodes incremental kv backup data
+func DecodeIncrement(data []byte) (*Increment, error) {
+	var inc *Increment // decoded increment
+	var err error      // general error holder
+
+	// check format
+	if !IsIncrement(data) {
+		return nil, ErrNotIncrement
+	}
+
+	// decode data
+	if err = json.Unmarshal(data, &inc); err != nil {
+		return nil, err
+	}
+
+	// all good
+	return inc, nil
+}
+
+// Keys returns the keys of the passed pairs
+func Keys(kvps api.KVPairs) []string {
+	var keys []string // output keys
+	for _, kv := range kvps {
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
@@ -0,0 +1,128 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Change describes a key present in both sets with a different value or flags
+type Change struct {
+	Key      string
+	OldFlags uint64
+	NewFlags uint64
+	OldValue []byte `json:"-"`
+	NewValue []byte `json:"-"`
+	Diff     string `json:",omitempty"`
+}
+
+// Changes describes the differences between two sets of keys
+type Changes struct {
+	Added    []string
+	Removed  []string
+	Modified []*Change
+}
+
+// Decode decodes full kv backup data
+func Decode(data []byte) (api.KVPairs, error) {
+	var kvps api.KVPairs // decoded pairs
+	var err error        // general error holder
+
+	// increments can not be used on their own
+	if IsIncrement(data) {
+		return nil, ErrIncrement
+	}
+
+	// decode data
+	if err = json.Unmarshal(data, &kvps); err != nil {
+		return nil, err
+	}
+
+	// all good
+	return kvps, nil
+}
+
+// Filter returns the pairs located under the passed prefix.
+// An empty prefix or the root prefix returns all pairs.
+func Filter(kvps api.KVPairs, prefix string) api.KVPairs {
+	var out api.KVPairs // filtered pairs
+
+	// check prefix
+	if prefix == "" || prefix == "/" {
+		return kvps
+	}
+
+	// loop through and filter pairs
+	for _, kv := range kvps {
+		if strings.HasPrefix(kv.Key, prefix) {
+			out = append(out, kv)
+		}
+	}
+
+	// return filtered pairs
+	return out
+}
+
+// Compare returns the changes needed to turn the current set
+// of keys into the desired set of keys ordered by key
+func Compare(current, desired api.KVPairs) *Changes {
+	var existing map[string]*api.KVPair // current pairs by key
+	var seen map[string]bool            // desired keys
+	var changes *Changes                // output changes
+
+	// init
+	existing = make(map[string]*api.KVPair, len(current))
+	seen = make(map[string]bool, len(desired))
+	changes = new(Changes)
+
+	// index current keys
+	for _, kv := range current {
+		existing[kv.Key] = kv
+	}
+
+	// loop through desired keys
+	for _, kv := range desired {
+		seen[kv.Key] = true
+		// check for existing key
+		old, ok := existing[kv.Key]
+		if !ok {
+			changes.Added = append(changes.Added, kv.Key)
+			continue
+		}
+		// compare value and flags
+		if old.Flags != kv.Flags || !bytes.Equal(old.Value, kv.Value) {
+			changes.Modified = append(changes.Modified, &Change{
+				Key:      kv.Key,
+				OldFlags: old.Flags,
+				NewFlags: kv.Flags,
+				OldValue: old.Value,
+				NewValue: kv.Value,
+			})
+		}
+	}
+
+	// find current keys not present in the desired set
+	for _, kv := range current {
+		if !seen[kv.Key] {
+			changes.Removed = append(changes.Removed, kv.Key)
+		}
+	}
+
+	// sort output
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Slice(changes.Modified, func(i, j int) bool {
+		return changes.Modified[i].Key < changes.Modified[j].Key
+	})
+
+	// return changes
+	return changes
+}
+
+// Empty checks if there are no changes
+func (c *Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
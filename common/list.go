@@ -0,0 +1,103 @@
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// sigSuffix is appended to a backup location to form the signature location
+const sigSuffix = ".sig"
+
+// BackupInfo describes a backup object found at a location
+type BackupInfo struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+	Signed   bool
+}
+
+// ListBackups returns the backups in a local directory or registered storage
+// backend matching the passed glob pattern ordered newest first.
+// Signature objects are reported with the backup they belong to.
+func ListBackups(pattern string) ([]*BackupInfo, error) {
+	var store Store           // storage backend
+	var backups []*BackupInfo // found backups
+	var sigs map[string]bool  // found signatures
+	var out []*BackupInfo     // output backups
+	var err error             // general error holder
+
+	// find backend and list objects
+	if store, err = openStore(pattern); err != nil {
+		return nil, err
+	}
+	if backups, err = store.List(pattern); err != nil {
+		return nil, err
+	}
+
+	// separate signatures
+	sigs = make(map[string]bool)
+	for _, b := range backups {
+		if name, ok := signedName(b.Name); ok {
+			sigs[name] = true
+			continue
+		}
+		out = append(out, b)
+	}
+
+	// mark signed backups
+	for _, b := range out {
+		b.Signed = sigs[b.Name]
+	}
+
+	// sort newest first - names break ties as timestamped names sort in order
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Modified.Equal(out[j].Modified) {
+			return out[i].Name > out[j].Name
+		}
+		return out[i].Modified.After(out[j].Modified)
+	})
+
+	// return backups
+	return out, nil
+}
+
+// StatBackup returns the details of a backup in a local
+// directory or registered storage backend
+func StatBackup(name string) (*BackupInfo, error) {
+	var store Store      // storage backend
+	var info *BackupInfo // backup details
+	var err error        // general error holder
+
+	// find backend and object
+	if store, err = openStore(name); err != nil {
+		return nil, err
+	}
+	if info, err = store.Stat(name); err != nil {
+		return nil, err
+	}
+
+	// check signature
+	_, err = store.Stat(signatureName(name))
+	info.Signed = err == nil
+
+	// return details
+	return info, nil
+}
+
+// DeleteBackup removes a backup and signature from a local
+// directory or registered storage backend
+func DeleteBackup(name string) error {
+	var store Store // storage backend
+	var err error   // general error holder
+
+	// find backend
+	if store, err = openStore(name); err != nil {
+		return err
+	}
+
+	// remove data and signature
+	if err = store.Delete(name); err != nil {
+		return err
+	}
+	return store.Delete(signatureName(name))
+}
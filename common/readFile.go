@@ -6,16 +6,44 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"io"
-	"os"
+	"io/ioutil"
 )
 
-// readBytes reads an encrypted/compressed steam from an io.Reader
-// and returns a decoded byte slice
-func readBytes(in io.Reader, key string) ([]byte, error) {
-	var gzReader *gzip.Reader  // compressed reader
+// readBytes reads an encrypted/compressed stream from an io.Reader
+// and returns a decoded byte slice.  Streams written before the
+// authenticated format are read with the legacy cipher.
+func readBytes(in io.Reader, keys *Keys) ([]byte, error) {
+	var data []byte       // raw data
+	var compressed []byte // decrypted data
+	var err error         // general error holder
+
+	// read raw data
+	if data, err = ioutil.ReadAll(in); err != nil {
+		return nil, err
+	}
+
+	// check format and decrypt
+	switch {
+	case isEncryptedTo(data):
+		compressed, err = decryptWith(keys.Identities, data)
+	case isSealed(data):
+		compressed, err = open(keys.Passphrase, data)
+	default:
+		return readLegacyBytes(bytes.NewReader(data), keys.Passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// decompress and return
+	return gunzip(bytes.NewReader(compressed))
+}
+
+// readLegacyBytes reads a stream encrypted with the legacy unauthenticated
+// cipher using a zero initialization vector and unsalted key
+func readLegacyBytes(in io.Reader, key string) ([]byte, error) {
 	var iv [aes.BlockSize]byte // initialization vector
 	var cb cipher.Block        // cipher block interface
-	var outBytes *bytes.Buffer // output buffer
 	var err error              // general error handler
 
 	// init cipher block
@@ -23,14 +51,21 @@ func readBytes(in io.Reader, key string) ([]byte, error) {
 		return nil, err
 	}
 
-	// init encrypted reader
-	encReader := &cipher.StreamReader{
+	// decompress and decrypt along the way
+	return gunzip(&cipher.StreamReader{
 		S: cipher.NewOFB(cb, iv[:]),
 		R: in,
-	}
+	})
+}
 
-	// wrap encrypted reader
-	if gzReader, err = gzip.NewReader(encReader); err != nil {
+// gunzip decompresses a stream and returns a decoded byte slice
+func gunzip(in io.Reader) ([]byte, error) {
+	var gzReader *gzip.Reader  // compressed reader
+	var outBytes *bytes.Buffer // output buffer
+	var err error              // general error handler
+
+	// wrap reader
+	if gzReader, err = gzip.NewReader(in); err != nil {
 		return nil, err
 	}
 
@@ -40,37 +75,9 @@ func readBytes(in io.Reader, key string) ([]byte, error) {
 	// init output
 	outBytes = new(bytes.Buffer)
 
-	// read data into output buffer decompressing and decrypting along the way
+	// read data into output buffer decompressing along the way
 	_, err = io.Copy(outBytes, gzReader)
 
 	// return bytes and last error state
 	return outBytes.Bytes(), err
 }
-
-// readFile reads an encrypted/compressed file and validates checksums
-func readFile(fname, key string) ([]byte, error) {
-	var in *os.File     // input file
-	var outBytes []byte // output buffer
-	var err error       // general error handler
-
-	// open source file
-	if in, err = os.Open(fname); err != nil {
-		return nil, err
-	}
-
-	// close when done
-	defer in.Close()
-
-	// read and decode file bytes
-	if outBytes, err = readBytes(in, key); err != nil {
-		return nil, err
-	}
-
-	// validate signature file and data
-	if err = validateFileChecksum(fname+".sig", key, outBytes); err != nil {
-		return nil, err
-	}
-
-	// return bytes and last error state
-	return outBytes, err
-}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// Retention describes how many backups to keep.  The newest backups are kept
+// up to Last along with backups modified Within the duration before now and
+// the newest backup of each of the most recent Daily days, Weekly weeks and
+// Monthly months.  Backups kept by any rule are kept.
+type Retention struct {
+	Last    int
+	Within  time.Duration
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// Empty checks if the retention policy keeps everything
+func (r *Retention) Empty() bool {
+	return r.Last <= 0 && r.Within <= 0 && r.Daily <= 0 && r.Weekly <= 0 && r.Monthly <= 0
+}
+
+// Expired returns the backups outside the retention policy.
+// Backups must be ordered newest first as returned by ListBackups.
+func (r *Retention) Expired(backups []*BackupInfo) []*BackupInfo {
+	var keep map[*BackupInfo]bool // kept backups
+	var out []*BackupInfo         // expired backups
+
+	// keep everything without a policy
+	if r.Empty() {
+		return nil
+	}
+
+	// init
+	keep = make(map[*BackupInfo]bool, len(backups))
+
+	// keep the newest backups
+	for i := 0; i < r.Last && i < len(backups); i++ {
+		keep[backups[i]] = true
+	}
+
+	// keep recent backups
+	if r.Within > 0 {
+		cutoff := time.Now().Add(-r.Within)
+		for _, b := range backups {
+			if b.Modified.After(cutoff) {
+				keep[b] = true
+			}
+		}
+	}
+
+	// keep the newest backup of each period
+	keepPeriods(backups, keep, r.Daily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPeriods(backups, keep, r.Weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%02d", year, week)
+	})
+	keepPeriods(backups, keep, r.Monthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	// collect everything else
+	for _, b := range backups {
+		if !keep[b] {
+			out = append(out, b)
+		}
+	}
+
+	// return expired backups
+	return out
+}
+
+// keepPeriods marks the newest backup in each of the most recent
+// count periods as kept using the passed period naming function
+func keepPeriods(backups []*BackupInfo, keep map[*BackupInfo]bool, count int, period func(time.Time) string) {
+	var seen map[string]bool // seen periods
+
+	// init
+	seen = make(map[string]bool, count)
+
+	// loop through backups newest first
+	for _, b := range backups {
+		if len(seen) >= count {
+			return
+		}
+		if p := period(b.Modified.UTC()); !seen[p] {
+			seen[p] = true
+			keep[b] = true
+		}
+	}
+}
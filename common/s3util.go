@@ -4,7 +4,6 @@ import (
 	"errors"
 	"net/url"
 	"strconv"
-	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -24,11 +23,6 @@ type s3Info struct {
 	key       string
 }
 
-// isS3 does a very basic check if the given string *could* be an S3 URI
-func isS3(s string) bool {
-	return strings.HasPrefix(s, "s3://") || strings.HasPrefix(s, "s3n://")
-}
-
 // parseS3URI returns a struct containing all the information needed to connect
 // to an S3 endpoing and create or retrieve objects.  The data is collected from
 // parsing the passed s3uri and environment variables.
@@ -38,30 +32,11 @@ func parseS3URI(s3uri string) (*s3Info, error) {
 	var accessKey, secretKey string // key holders
 	var err error                   // general error holder
 
-	// The `net/url` package does not handle '/' in password.
-	// Therefore, we strip out and parse the user/password portion manually.
-	// See: https://github.com/myENA/consul-backinator/issues/30
-	if strings.Contains(s3uri, "@") {
-		var keyStart = strings.Index(s3uri, "://") + 3 // get start
-		var keyEnd = strings.Index(s3uri, "@")         // get end
-		var keyString = s3uri[keyStart:keyEnd]         // pull out key string
-		// check key string
-		if strings.Contains(keyString, ":") {
-			// split the keys
-			var keySplit = strings.Split(keyString, ":")
-			// check split
-			if len(keySplit) == 2 {
-				// set keys
-				accessKey = keySplit[0]
-				secretKey = keySplit[1]
-				// rewrite uri - remove credentials
-				s3uri = s3uri[:keyStart] + s3uri[keyEnd+1:]
-			}
-		}
-	}
+	// strip out credentials
+	accessKey, secretKey, s3uri = splitCredentials(s3uri)
 
 	// parse the s3 path
-	if u, err = url.Parse(s3uri); err != nil {
+	if u, err = parseURI(s3uri); err != nil {
 		return nil, err
 	}
 
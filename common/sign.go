@@ -0,0 +1,175 @@
+package common
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// signatureType is the leading field of a public key signature
+const signatureType = "ed25519"
+
+// Exported signature errors
+var (
+	ErrBadKeyFile       = errors.New("Key file does not contain a PEM encoded ed25519 key")
+	ErrMissingVerifyKey = errors.New("Backup is signed with a private key.  " +
+		"Please pass the public key of the signer to validate the signature.")
+	ErrNotPublicSignature = errors.New("Backup signature is not a public key signature.  " +
+		"Please pass the passphrase used to create the backup instead of a public key.")
+)
+
+// AddSigningKey reads a PEM encoded ed25519 private key used to sign
+// backups.  Keys may be generated with: openssl genpkey -algorithm ed25519
+func (k *Keys) AddSigningKey(fname string) error {
+	var key interface{}  // parsed key
+	var block *pem.Block // pem block
+	var ok bool          // assert check
+	var err error        // general error holder
+
+	// read and decode key file
+	if block, err = readPEM(fname); err != nil {
+		return err
+	}
+
+	// parse key
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		return err
+	}
+
+	// check type
+	if k.Signer, ok = key.(ed25519.PrivateKey); !ok {
+		return ErrBadKeyFile
+	}
+
+	// all good
+	return nil
+}
+
+// AddVerifyKey reads a PEM encoded ed25519 public key used to validate backup
+// signatures.  Keys may be exported with: openssl pkey -in private.pem -pubout
+func (k *Keys) AddVerifyKey(fname string) error {
+	var key interface{}  // parsed key
+	var block *pem.Block // pem block
+	var ok bool          // assert check
+	var err error        // general error holder
+
+	// read and decode key file
+	if block, err = readPEM(fname); err != nil {
+		return err
+	}
+
+	// parse key
+	if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return err
+	}
+
+	// check type
+	if k.Verifier, ok = key.(ed25519.PublicKey); !ok {
+		return ErrBadKeyFile
+	}
+
+	// all good
+	return nil
+}
+
+// SignerFingerprint returns the fingerprint of the signing key
+// or an empty string when backups are signed with the passphrase
+func (k *Keys) SignerFingerprint() string {
+	if k.Signer == nil {
+		return ""
+	}
+	return Fingerprint(k.Signer.Public().(ed25519.PublicKey))
+}
+
+// Fingerprint returns the SHA256 fingerprint of a public key
+// in the same format used by ssh-keygen
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// isPublicSignature checks if signature data is a public key signature
+func isPublicSignature(sig []byte) bool {
+	return bytes.HasPrefix(sig, []byte(signatureType+" "))
+}
+
+// signRaw returns a public key signature of the encrypted backup data.
+// The signature line contains the key type, signer fingerprint and signature.
+func signRaw(key ed25519.PrivateKey, raw []byte) []byte {
+	return []byte(fmt.Sprintf("%s %s %s\n",
+		signatureType,
+		Fingerprint(key.Public().(ed25519.PublicKey)),
+		base64.StdEncoding.EncodeToString(ed25519.Sign(key, raw))))
+}
+
+// verifyRaw validates a public key signature of the encrypted backup data
+func verifyRaw(key ed25519.PublicKey, raw, sig []byte) error {
+	var fields []string // signature fields
+	var decoded []byte  // decoded signature
+	var err error       // general error holder
+
+	// check key
+	if key == nil {
+		return ErrMissingVerifyKey
+	}
+
+	// split and check signature line
+	if fields = strings.Fields(string(sig)); len(fields) != 3 || fields[0] != signatureType {
+		return ErrBadSignature
+	}
+
+	// check signer
+	if fields[1] != Fingerprint(key) {
+		return fmt.Errorf("Backup was signed by %s and can not be validated with %s",
+			fields[1], Fingerprint(key))
+	}
+
+	// decode signature
+	if decoded, err = base64.StdEncoding.DecodeString(fields[2]); err != nil {
+		return ErrBadSignature
+	}
+
+	// validate signature
+	if !ed25519.Verify(key, raw, decoded) {
+		return ErrBadSignature
+	}
+
+	// all good
+	return nil
+}
+
+// SignatureFingerprint returns the signer fingerprint recorded
+// in a public key signature or an empty string
+func SignatureFingerprint(sig []byte) string {
+	if fields := strings.Fields(string(sig)); isPublicSignature(sig) && len(fields) == 3 {
+		return fields[1]
+	}
+	return ""
+}
+
+// readPEM reads the first PEM block from a file
+func readPEM(fname string) (*pem.Block, error) {
+	var data []byte      // file data
+	var block *pem.Block // pem block
+	var err error        // general error holder
+
+	// read file
+	if data, err = ioutil.ReadFile(fname); err != nil {
+		return nil, err
+	}
+
+	// decode block
+	if block, _ = pem.Decode(data); block == nil {
+		return nil, ErrBadKeyFile
+	}
+
+	// all good
+	return block, nil
+}
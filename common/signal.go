@@ -0,0 +1,38 @@
+package common
+
+import (
+	stdLog "log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// StopOnSignal returns a channel closed when the process receives an interrupt
+// or termination signal and a function that stops listening for signals
+func StopOnSignal(log *stdLog.Logger) (<-chan struct{}, func()) {
+	var signals chan os.Signal // os signals
+	var stop chan struct{}     // stop signal
+	var quit chan struct{}     // listener stop signal
+
+	// init
+	signals = make(chan os.Signal, 1)
+	stop = make(chan struct{})
+	quit = make(chan struct{})
+
+	// listen for signals
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("[Info] Received %s signal, stopping", sig)
+			close(stop)
+		case <-quit:
+		}
+	}()
+
+	// return stop signal and cleanup
+	return stop, func() {
+		signal.Stop(signals)
+		close(quit)
+	}
+}
@@ -0,0 +1,247 @@
+package common
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrUnknownStore is returned for locations with an unregistered uri scheme
+var ErrUnknownStore = errors.New("no storage backend registered for location scheme")
+
+// schemeSeparator separates the scheme from the rest of a location uri
+const schemeSeparator = "://"
+
+// queryStart matches the start of uri options so a '?' glob
+// character in an object path is not taken for the query
+var queryStart = regexp.MustCompile(`\?[A-Za-z_][A-Za-z0-9_]*=`)
+
+// Store is a storage backend holding raw backup objects.  Objects are named
+// by their full location including the scheme, credentials and options
+// so each name passed to or returned by a store may be used on its own.
+type Store interface {
+	// Put creates or replaces an object
+	Put(name string, data []byte) error
+	// Get returns the contents of an object
+	Get(name string) ([]byte, error)
+	// List returns the objects matching the glob pattern in the
+	// object path along with their signature objects
+	List(pattern string) ([]*BackupInfo, error)
+	// Delete removes an object.  Removing a missing object is not an error.
+	Delete(name string) error
+	// Stat returns the size and modification time of an object
+	Stat(name string) (*BackupInfo, error)
+}
+
+// stores contains the registered backends keyed by uri scheme
+var stores = make(map[string]Store)
+
+// localStore is used for locations without a scheme
+var localStore Store = new(fileStore)
+
+// RegisterStore makes a storage backend available for locations using the
+// passed uri scheme.  Registering a scheme twice replaces the earlier backend.
+func RegisterStore(scheme string, s Store) {
+	stores[scheme] = s
+}
+
+// openStore returns the storage backend for a location
+func openStore(location string) (Store, error) {
+	var scheme string // location scheme
+
+	// locations without a scheme are local files
+	if scheme = locationScheme(location); scheme == "" {
+		return localStore, nil
+	}
+
+	// find backend
+	if s, ok := stores[scheme]; ok {
+		return s, nil
+	}
+
+	// not found
+	return nil, ErrUnknownStore
+}
+
+// locationScheme returns the uri scheme of a location or
+// an empty string for local files
+func locationScheme(location string) string {
+	if i := strings.Index(location, schemeSeparator); i > 0 &&
+		!strings.ContainsAny(location[:i], `/\`) {
+		return location[:i]
+	}
+	return ""
+}
+
+// credentialsEnd returns the offset of the '@' ending the credentials of a
+// location uri or -1 when there are none.  Credentials are only found in the
+// authority before the object path.  A secret may contain a '/' so the
+// authority is extended past a '/' only for a 'user:secret@' prefix
+// where the '/' follows the ':' and the ':' does not start a port.
+func credentialsEnd(uri string) int {
+	var start, at, slash, colon int // uri offsets
+
+	// find candidates
+	start = strings.Index(uri, schemeSeparator) + len(schemeSeparator)
+	if at = strings.Index(uri[start:], "@"); at < 0 {
+		return -1
+	}
+	at += start
+	if slash = strings.Index(uri[start:], "/"); slash < 0 || start+slash > at {
+		return at
+	}
+	slash += start
+
+	// check for a secret containing the '/'
+	if strings.Contains(uri[start:at], "?") {
+		return -1
+	}
+	if colon = strings.Index(uri[start:slash], ":"); colon < 0 {
+		return -1
+	}
+	colon += start
+	if strings.Trim(uri[colon+1:slash], "0123456789") == "" {
+		return -1
+	}
+
+	// return credentials end
+	return at
+}
+
+// splitURI splits a location uri into the scheme, credentials and host,
+// the object path and the query without parsing or validating any part.
+// The query starts at the first '?' followed by an option name and '='.
+func splitURI(uri string) (string, string, string) {
+	var hostStart, pathStart, queryAt int // uri offsets
+
+	// skip scheme and credentials which may contain a '/'
+	hostStart = strings.Index(uri, schemeSeparator) + len(schemeSeparator)
+	if at := credentialsEnd(uri); at >= 0 {
+		hostStart = at + 1
+	}
+
+	// find query
+	if loc := queryStart.FindStringIndex(uri[hostStart:]); loc != nil {
+		queryAt = hostStart + loc[0]
+	} else {
+		queryAt = len(uri)
+	}
+
+	// find path
+	if pathStart = strings.Index(uri[hostStart:queryAt], "/"); pathStart < 0 {
+		pathStart = queryAt
+	} else {
+		pathStart += hostStart
+	}
+
+	// return parts
+	return uri[:pathStart], uri[pathStart:queryAt], uri[queryAt:]
+}
+
+// parseURI parses a location uri keeping '?' and '@' characters in the
+// object path that would otherwise be taken for the query or credentials
+func parseURI(uri string) (*url.URL, error) {
+	var u *url.URL // parsed url
+	var err error  // general error holder
+
+	// parse everything but the path
+	head, p, query := splitURI(uri)
+	if u, err = url.Parse(head + query); err != nil {
+		return nil, err
+	}
+
+	// set the decoded path
+	if u.Path, err = url.PathUnescape(p); err != nil {
+		u.Path = p
+	}
+
+	// return url
+	return u, nil
+}
+
+// splitCredentials removes a user and password from a location uri.
+// The `net/url` package does not handle '/' in password.
+// Therefore, we strip out and parse the user/password portion manually.
+// See: https://github.com/myENA/consul-backinator/issues/30
+func splitCredentials(uri string) (string, string, string) {
+	var keyStart, keyEnd int // credential offsets
+	var keySplit []string    // split credentials
+
+	// find credentials
+	keyStart = strings.Index(uri, schemeSeparator) + len(schemeSeparator)
+	if keyEnd = credentialsEnd(uri); keyEnd < keyStart {
+		return "", "", uri
+	}
+
+	// split the keys
+	if keySplit = strings.Split(uri[keyStart:keyEnd], ":"); len(keySplit) != 2 {
+		return "", "", uri
+	}
+
+	// return keys and the uri without credentials
+	return keySplit[0], keySplit[1], uri[:keyStart] + uri[keyEnd+1:]
+}
+
+// replacePath returns a location uri with the object path replaced
+// so credentials and options are kept for other objects
+func replacePath(uri, p string) string {
+	head, _, query := splitURI(uri)
+	return head + "/" + strings.TrimPrefix(p, "/") + query
+}
+
+// AppendSuffix appends a suffix to the path of a local file or storage
+// location keeping any options at the end of a uri
+func AppendSuffix(name, suffix string) string {
+	// local files
+	if locationScheme(name) == "" {
+		return name + suffix
+	}
+	// keep options at the end of a uri
+	_, p, _ := splitURI(name)
+	return replacePath(name, p+suffix)
+}
+
+// signatureName returns the location of the signature for a backup
+func signatureName(name string) string {
+	return AppendSuffix(name, sigSuffix)
+}
+
+// signedName returns the backup location a signature belongs to
+// and false when the passed location is not a signature
+func signedName(name string) (string, bool) {
+	var p string // object path
+
+	// local files
+	if locationScheme(name) == "" {
+		return strings.TrimSuffix(name, sigSuffix), strings.HasSuffix(name, sigSuffix)
+	}
+
+	// check the path of a uri
+	if _, p, _ = splitURI(name); !strings.HasSuffix(p, sigSuffix) {
+		return name, false
+	}
+	return replacePath(name, strings.TrimSuffix(p, sigSuffix)), true
+}
+
+// globPrefix returns the fixed portion of a glob pattern
+func globPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?[\\"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// JoinPattern returns a glob pattern matching names in a local
+// directory or under a prefix of any registered backend
+func JoinPattern(location, match string) string {
+	// keep credentials and options of a uri
+	if locationScheme(location) != "" {
+		_, p, _ := splitURI(location)
+		return replacePath(location, path.Join(strings.TrimPrefix(p, "/"), match))
+	}
+	// still going ... attempt file
+	return filepath.Join(location, match)
+}
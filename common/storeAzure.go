@@ -0,0 +1,289 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+// Exported error messages
+var (
+	ErrAzureMissingContainerBlob = errors.New("missing Azure container or blob name")
+	ErrAzureMissingAccount       = errors.New("missing Azure storage account name")
+)
+
+// Azure environment variables
+const (
+	azureAccountEnv = "AZURE_STORAGE_ACCOUNT"
+	azureKeyEnv     = "AZURE_STORAGE_KEY"
+	azureSASEnv     = "AZURE_STORAGE_SAS_TOKEN"
+)
+
+// azureStorageResource is the managed identity token resource for blob storage
+const azureStorageResource = "https://storage.azure.com/"
+
+// register backend
+func init() {
+	RegisterStore("azblob", new(azureStore))
+}
+
+// azureStore stores backups in Azure Blob Storage
+type azureStore struct{}
+
+// azureInfo contains the information needed to connect to an Azure
+// storage account and create or retrieve blobs
+type azureInfo struct {
+	container azblob.ContainerURL
+	blob      string
+}
+
+// parseAzureURI returns a struct containing all the information needed to
+// connect to an Azure storage container.  The data is collected from
+// parsing the passed uri and environment variables.  Shared keys are
+// preferred over SAS tokens with managed identities used otherwise.
+// SAS tokens are only read from the environment because their fields
+// would be split into separate uri options unless encoded.
+func parseAzureURI(uri string) (*azureInfo, error) {
+	var account, key string          // account credentials
+	var u, endpoint *url.URL         // parsed urls
+	var credential azblob.Credential // request credential
+	var sas string                   // shared access signature
+	var err error                    // general error holder
+
+	// strip out credentials
+	account, key, uri = splitCredentials(uri)
+
+	// parse the azure path
+	if u, err = parseURI(uri); err != nil {
+		return nil, err
+	}
+	if u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return nil, ErrAzureMissingContainerBlob
+	}
+
+	// get account
+	if account == "" && u.User != nil {
+		account = u.User.Username()
+	}
+	if account == "" {
+		account = os.Getenv(azureAccountEnv)
+	}
+	if account == "" {
+		return nil, ErrAzureMissingAccount
+	}
+
+	// get endpoint
+	if temps := u.Query().Get("endpoint"); temps != "" {
+		if endpoint, err = url.Parse(strings.TrimSuffix(temps, "/")); err != nil {
+			return nil, err
+		}
+	} else {
+		endpoint = &url.URL{Scheme: "https", Host: account + ".blob.core.windows.net"}
+	}
+	endpoint.Path += "/" + u.Host
+
+	// get credential
+	if key == "" {
+		key = os.Getenv(azureKeyEnv)
+	}
+	sas = os.Getenv(azureSASEnv)
+	switch {
+	case key != "":
+		credential, err = azblob.NewSharedKeyCredential(account, key)
+	case sas != "":
+		credential = azblob.NewAnonymousCredential()
+		endpoint.RawQuery = strings.TrimPrefix(sas, "?")
+	default:
+		credential, err = azureIdentityCredential()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// return populated struct
+	return &azureInfo{
+		container: azblob.NewContainerURL(*endpoint, azblob.NewPipeline(credential, azblob.PipelineOptions{})),
+		blob:      strings.TrimPrefix(u.Path, "/"),
+	}, nil
+}
+
+// azureIdentityCredential returns a credential using a token
+// from the managed identity of the current host
+func azureIdentityCredential() (azblob.Credential, error) {
+	var endpoint string                   // token endpoint
+	var token *adal.ServicePrincipalToken // identity token
+	var err error                         // general error holder
+
+	// fetch token
+	if endpoint, err = adal.GetMSIVMEndpoint(); err != nil {
+		return nil, err
+	}
+	if token, err = adal.NewServicePrincipalTokenFromMSI(endpoint, azureStorageResource); err != nil {
+		return nil, err
+	}
+	if err = token.Refresh(); err != nil {
+		return nil, fmt.Errorf("failed to get managed identity token: %s", err.Error())
+	}
+
+	// each operation uses a new credential so the token is never refreshed
+	return azblob.NewTokenCredential(token.Token().AccessToken, nil), nil
+}
+
+// isAzureCode checks if an error is a storage error with the passed code
+func isAzureCode(err error, code azblob.ServiceCodeType) bool {
+	serr, ok := err.(azblob.StorageError)
+	return ok && serr.ServiceCode() == code
+}
+
+// isAzureForbidden checks if an error is a storage error caused by
+// credentials limited to blobs such as a container scoped SAS token
+func isAzureForbidden(err error) bool {
+	serr, ok := err.(azblob.StorageError)
+	return ok && serr.Response() != nil && serr.Response().StatusCode == http.StatusForbidden
+}
+
+// Put uploads a blob creating the container when needed
+func (as *azureStore) Put(name string, data []byte) error {
+	var ctx = context.Background() // request context
+	var info *azureInfo            // azure info struct
+	var err error                  // general error holder
+
+	// init
+	if info, err = parseAzureURI(name); err != nil {
+		return err
+	}
+
+	// attempt to create container
+	if _, err = info.container.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone); err != nil &&
+		!isAzureCode(err, azblob.ServiceCodeContainerAlreadyExists) &&
+		!isAzureForbidden(err) {
+		// not something we catch - return the error
+		return err
+	}
+
+	// upload blob
+	_, err = azblob.UploadBufferToBlockBlob(ctx, data, info.container.NewBlockBlobURL(info.blob),
+		azblob.UploadToBlockBlobOptions{})
+
+	// return upload error
+	return err
+}
+
+// Get downloads a blob
+func (as *azureStore) Get(name string) ([]byte, error) {
+	var ctx = context.Background()    // request context
+	var info *azureInfo               // azure info struct
+	var resp *azblob.DownloadResponse // download response
+	var err error                     // general error holder
+
+	// init
+	if info, err = parseAzureURI(name); err != nil {
+		return nil, err
+	}
+
+	// fetch blob
+	if resp, err = info.container.NewBlobURL(info.blob).Download(ctx, 0, azblob.CountToEnd,
+		azblob.BlobAccessConditions{}, false); err != nil {
+		return nil, err
+	}
+
+	// read blob
+	body := resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// List returns blobs in a container matching the glob pattern
+// in the blob name along with their signature blobs
+func (as *azureStore) List(pattern string) ([]*BackupInfo, error) {
+	var ctx = context.Background() // request context
+	var info *azureInfo            // azure info struct
+	var out []*BackupInfo          // found blobs
+	var err error                  // general error holder
+
+	// init
+	if info, err = parseAzureURI(pattern); err != nil {
+		return nil, err
+	}
+
+	// only list blobs under the fixed portion of the pattern
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		var resp *azblob.ListBlobsFlatSegmentResponse // listed page
+		if resp, err = info.container.ListBlobsFlatSegment(ctx, marker,
+			azblob.ListBlobsSegmentOptions{Prefix: globPrefix(info.blob)}); err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			// match data and signature blobs
+			if ok, _ := path.Match(info.blob, strings.TrimSuffix(item.Name, sigSuffix)); !ok {
+				continue
+			}
+			b := &BackupInfo{
+				Name:     replacePath(pattern, item.Name),
+				Modified: item.Properties.LastModified,
+			}
+			if item.Properties.ContentLength != nil {
+				b.Size = *item.Properties.ContentLength
+			}
+			out = append(out, b)
+		}
+		marker = resp.NextMarker
+	}
+
+	// return blobs
+	return out, nil
+}
+
+// Delete removes a blob
+func (as *azureStore) Delete(name string) error {
+	var ctx = context.Background() // request context
+	var info *azureInfo            // azure info struct
+	var err error                  // general error holder
+
+	// init
+	if info, err = parseAzureURI(name); err != nil {
+		return err
+	}
+
+	// delete blob
+	if _, err = info.container.NewBlobURL(info.blob).Delete(ctx, azblob.DeleteSnapshotsOptionInclude,
+		azblob.BlobAccessConditions{}); isAzureCode(err, azblob.ServiceCodeBlobNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Stat returns blob details
+func (as *azureStore) Stat(name string) (*BackupInfo, error) {
+	var ctx = context.Background()              // request context
+	var info *azureInfo                         // azure info struct
+	var props *azblob.BlobGetPropertiesResponse // blob details
+	var err error                               // general error holder
+
+	// init
+	if info, err = parseAzureURI(name); err != nil {
+		return nil, err
+	}
+
+	// fetch blob details
+	if props, err = info.container.NewBlobURL(info.blob).GetProperties(ctx,
+		azblob.BlobAccessConditions{}); err != nil {
+		return nil, err
+	}
+
+	// return details
+	return &BackupInfo{
+		Name:     name,
+		Size:     props.ContentLength(),
+		Modified: props.LastModified(),
+	}, nil
+}
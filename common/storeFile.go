@@ -0,0 +1,107 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// fileStore stores backups in the local filesystem
+type fileStore struct{}
+
+// Put writes a file that is only accessible by the current executer
+func (fs *fileStore) Put(name string, data []byte) error {
+	var out *os.File // destination file
+	var err error    // general error holder
+
+	// open destination file and create/overwite if neeeded
+	// and ensure it's only accessible by the current executer
+	if out, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
+		return err
+	}
+
+	// write data
+	if _, err = out.Write(data); err != nil {
+		out.Close()
+		return err
+	}
+
+	// return close error
+	return out.Close()
+}
+
+// Get reads a file
+func (fs *fileStore) Get(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+// List returns local files matching the passed glob
+// pattern along with their signature files
+func (fs *fileStore) List(pattern string) ([]*BackupInfo, error) {
+	var names, sigs []string // matched names
+	var out []*BackupInfo    // found files
+	var err error            // general error holder
+
+	// find matching files and signatures
+	if names, err = filepath.Glob(pattern); err != nil {
+		return nil, err
+	}
+	if sigs, err = filepath.Glob(pattern + sigSuffix); err != nil {
+		return nil, err
+	}
+
+	// stat files
+	seen := make(map[string]bool, len(names)+len(sigs))
+	for _, name := range append(names, sigs...) {
+		var fi os.FileInfo // file info
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if fi, err = os.Stat(name); err != nil {
+			return nil, err
+		}
+		if fi.IsDir() {
+			continue
+		}
+		out = append(out, fileInfo(name, fi))
+	}
+
+	// return files
+	return out, nil
+}
+
+// Delete removes a file
+func (fs *fileStore) Delete(name string) error {
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Stat returns file details
+func (fs *fileStore) Stat(name string) (*BackupInfo, error) {
+	var fi os.FileInfo // file info
+	var err error      // general error holder
+
+	// stat file
+	if fi, err = os.Stat(name); err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+
+	// return details
+	return fileInfo(name, fi), nil
+}
+
+// fileInfo converts file details
+func fileInfo(name string, fi os.FileInfo) *BackupInfo {
+	return &BackupInfo{
+		Name:     name,
+		Size:     fi.Size(),
+		Modified: fi.ModTime(),
+	}
+}
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// ErrGSMissingBucketKey is returned for gs uris without a bucket or object
+var ErrGSMissingBucketKey = errors.New("missing GCS bucket or object name")
+
+// gcsEmulatorEnv points the client at a local emulator when set
+const gcsEmulatorEnv = "STORAGE_EMULATOR_HOST"
+
+// register backend
+func init() {
+	RegisterStore("gs", new(gcsStore))
+}
+
+// gcsStore stores backups in Google Cloud Storage using
+// credentials from the application default chain
+type gcsStore struct{}
+
+// open parses a gs uri and returns a client and the bucket and object names
+func (gs *gcsStore) open(ctx context.Context, name string) (*storage.Client, string, string, error) {
+	var u *url.URL                 // parsed url
+	var opts []option.ClientOption // client options
+	var client *storage.Client     // gcs client
+	var err error                  // general error holder
+
+	// parse the gs path
+	if u, err = parseURI(name); err != nil {
+		return nil, "", "", err
+	}
+	if u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return nil, "", "", ErrGSMissingBucketKey
+	}
+
+	// the client only sends reads to an emulator on its own
+	if host := os.Getenv(gcsEmulatorEnv); host != "" {
+		host = strings.TrimPrefix(host, "http://")
+		opts = append(opts, option.WithEndpoint("http://"+host+"/storage/v1/"))
+	}
+
+	// init client
+	if client, err = storage.NewClient(ctx, opts...); err != nil {
+		return nil, "", "", err
+	}
+
+	// return client and names
+	return client, u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// Put uploads an object
+func (gs *gcsStore) Put(name string, data []byte) error {
+	var ctx = context.Background() // request context
+	var client *storage.Client     // gcs client
+	var bucket, object string      // object location
+	var w *storage.Writer          // object writer
+	var err error                  // general error holder
+
+	// init
+	if client, bucket, object, err = gs.open(ctx, name); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// upload object - errors are reported on close
+	w = client.Bucket(bucket).Object(object).NewWriter(ctx)
+	if _, err = w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Get downloads an object
+func (gs *gcsStore) Get(name string) ([]byte, error) {
+	var ctx = context.Background() // request context
+	var client *storage.Client     // gcs client
+	var bucket, object string      // object location
+	var r *storage.Reader          // object reader
+	var err error                  // general error holder
+
+	// init
+	if client, bucket, object, err = gs.open(ctx, name); err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	// fetch object
+	if r, err = client.Bucket(bucket).Object(object).NewReader(ctx); err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// read object
+	return ioutil.ReadAll(r)
+}
+
+// List returns objects in a bucket matching the glob pattern
+// in the object name along with their signature objects
+func (gs *gcsStore) List(pattern string) ([]*BackupInfo, error) {
+	var ctx = context.Background() // request context
+	var client *storage.Client     // gcs client
+	var bucket, object string      // pattern location
+	var it *storage.ObjectIterator // object iterator
+	var out []*BackupInfo          // found objects
+	var err error                  // general error holder
+
+	// init
+	if client, bucket, object, err = gs.open(ctx, pattern); err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	// only list objects under the fixed portion of the pattern
+	it = client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: globPrefix(object)})
+
+	// list objects
+	for {
+		var attrs *storage.ObjectAttrs // object details
+		if attrs, err = it.Next(); err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		// match data and signature objects
+		if ok, _ := path.Match(object, strings.TrimSuffix(attrs.Name, sigSuffix)); !ok {
+			continue
+		}
+		out = append(out, &BackupInfo{
+			Name:     replacePath(pattern, attrs.Name),
+			Size:     attrs.Size,
+			Modified: attrs.Updated,
+		})
+	}
+
+	// return objects
+	return out, nil
+}
+
+// Delete removes an object
+func (gs *gcsStore) Delete(name string) error {
+	var ctx = context.Background() // request context
+	var client *storage.Client     // gcs client
+	var bucket, object string      // object location
+	var err error                  // general error holder
+
+	// init
+	if client, bucket, object, err = gs.open(ctx, name); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// delete object
+	if err = client.Bucket(bucket).Object(object).Delete(ctx); err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+// Stat returns object details
+func (gs *gcsStore) Stat(name string) (*BackupInfo, error) {
+	var ctx = context.Background() // request context
+	var client *storage.Client     // gcs client
+	var bucket, object string      // object location
+	var attrs *storage.ObjectAttrs // object details
+	var err error                  // general error holder
+
+	// init
+	if client, bucket, object, err = gs.open(ctx, name); err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	// fetch object details
+	if attrs, err = client.Bucket(bucket).Object(object).Attrs(ctx); err != nil {
+		return nil, err
+	}
+
+	// return details
+	return &BackupInfo{
+		Name:     name,
+		Size:     attrs.Size,
+		Modified: attrs.Updated,
+	}, nil
+}
@@ -0,0 +1,209 @@
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// register backend
+func init() {
+	RegisterStore("s3", new(s3Store))
+	RegisterStore("s3n", new(s3Store))
+}
+
+// s3Store stores backups in an S3 datastore
+type s3Store struct{}
+
+// open parses an s3 uri and returns the location info and a client
+func (ss *s3Store) open(name string) (*s3Info, *s3.S3, error) {
+	var info *s3Info // s3 info struct
+	var err error    // general error holder
+
+	// parse name as s3 uri and validate
+	if info, err = parseS3URI(name); err != nil {
+		return nil, nil, err
+	}
+
+	// init s3 client
+	return info, s3.New(session.Must(session.NewSession(info.awsConfig))), nil
+}
+
+// Put uploads an object creating the bucket when needed
+func (ss *s3Store) Put(name string, data []byte) error {
+	var info *s3Info                        // s3 info struct
+	var s3Client *s3.S3                     // aws s3 client
+	var bucketRequest *s3.CreateBucketInput // aws create bucket request
+	var err error                           // general error holder
+	var awsErr awserr.Error                 // aws framework error
+	var ok bool                             // assert check
+
+	// init
+	if info, s3Client, err = ss.open(name); err != nil {
+		return err
+	}
+
+	// build create bucket request
+	bucketRequest = &s3.CreateBucketInput{
+		Bucket: aws.String(info.bucket),
+	}
+
+	// only add location constraint if needed
+	// http://docs.aws.amazon.com/general/latest/gr/rande.html#s3_region
+	if info.awsConfig.Region != nil && aws.StringValue(info.awsConfig.Region) != "us-east-1" {
+		bucketRequest.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: info.awsConfig.Region,
+		}
+	}
+
+	// attempt to create bucket
+	if _, err = s3Client.CreateBucket(bucketRequest); err != nil {
+		// ignore non-fatal creation errors
+		if awsErr, ok = err.(awserr.Error); ok {
+			if awsErr.Code() != s3.ErrCodeBucketAlreadyExists &&
+				awsErr.Code() != s3.ErrCodeBucketAlreadyOwnedByYou &&
+				awsErr.Code() != "AccessDenied" {
+				// not something we catch - return the error
+				return err
+			}
+		} else {
+			// other failure - return the error
+			return err
+		}
+	}
+
+	// upload object
+	_, err = s3Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(info.bucket),
+		Key:    aws.String(info.key),
+		Body:   bytes.NewReader(data),
+	})
+
+	// return upload error
+	return err
+}
+
+// Get downloads an object
+func (ss *s3Store) Get(name string) ([]byte, error) {
+	var info *s3Info               // s3 info struct
+	var s3Client *s3.S3            // aws s3 client
+	var object *s3.GetObjectOutput // fetched object
+	var err error                  // general error holder
+
+	// init
+	if info, s3Client, err = ss.open(name); err != nil {
+		return nil, err
+	}
+
+	// fetch object and check error
+	if object, err = s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(info.bucket),
+		Key:    aws.String(info.key),
+	}); err != nil {
+		return nil, err
+	}
+
+	// close when done
+	defer object.Body.Close()
+
+	// read object
+	return ioutil.ReadAll(object.Body)
+}
+
+// List returns objects in an S3 bucket matching the glob pattern
+// in the object key along with their signature objects
+func (ss *s3Store) List(pattern string) ([]*BackupInfo, error) {
+	var info *s3Info      // s3 info struct
+	var s3Client *s3.S3   // aws s3 client
+	var keyPattern string // object key pattern
+	var prefix string     // fixed portion of the pattern
+	var out []*BackupInfo // found objects
+	var err error         // general error holder
+
+	// init
+	if info, s3Client, err = ss.open(pattern); err != nil {
+		return nil, err
+	}
+
+	// only list objects under the fixed portion of the pattern
+	keyPattern = strings.TrimPrefix(info.key, "/")
+	prefix = globPrefix(keyPattern)
+
+	// list objects
+	err = s3Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(info.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			key := aws.StringValue(obj.Key)
+			// match data and signature objects
+			if ok, _ := path.Match(keyPattern, strings.TrimSuffix(key, sigSuffix)); !ok {
+				continue
+			}
+			out = append(out, &BackupInfo{
+				Name:     replacePath(pattern, key),
+				Size:     aws.Int64Value(obj.Size),
+				Modified: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+
+	// return objects
+	return out, err
+}
+
+// Delete removes an object
+func (ss *s3Store) Delete(name string) error {
+	var info *s3Info    // s3 info struct
+	var s3Client *s3.S3 // aws s3 client
+	var err error       // general error holder
+
+	// init
+	if info, s3Client, err = ss.open(name); err != nil {
+		return err
+	}
+
+	// delete object
+	_, err = s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(info.bucket),
+		Key:    aws.String(info.key),
+	})
+
+	// return delete error
+	return err
+}
+
+// Stat returns object details
+func (ss *s3Store) Stat(name string) (*BackupInfo, error) {
+	var info *s3Info              // s3 info struct
+	var s3Client *s3.S3           // aws s3 client
+	var head *s3.HeadObjectOutput // object details
+	var err error                 // general error holder
+
+	// init
+	if info, s3Client, err = ss.open(name); err != nil {
+		return nil, err
+	}
+
+	// fetch object details
+	if head, err = s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(info.bucket),
+		Key:    aws.String(info.key),
+	}); err != nil {
+		return nil, err
+	}
+
+	// return details
+	return &BackupInfo{
+		Name:     name,
+		Size:     aws.Int64Value(head.ContentLength),
+		Modified: aws.TimeValue(head.LastModified),
+	}, nil
+}
@@ -0,0 +1,369 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Exported error messages
+var (
+	ErrSFTPMissingHostPath = errors.New("missing SFTP host or file path")
+	ErrSFTPMissingAuth     = errors.New("no SSH identity file or agent available")
+)
+
+// sftp defaults
+const (
+	sftpDefaultPort = "22"
+	sftpTimeout     = 30 * time.Second
+	sftpPosixRename = "posix-rename@openssh.com"
+)
+
+// sftpIdentities are the private keys tried when no identity is passed
+var sftpIdentities = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// register backend
+func init() {
+	RegisterStore("sftp", new(sftpStore))
+}
+
+// sftpStore stores backups on a remote host over SSH
+type sftpStore struct{}
+
+// sftpConn is an sftp session and the ssh connection carrying it
+type sftpConn struct {
+	*sftp.Client
+	conn *ssh.Client
+}
+
+// Close ends the session and the connection
+func (sc *sftpConn) Close() error {
+	sc.Client.Close()
+	return sc.conn.Close()
+}
+
+// sshDir returns the ssh configuration directory of the current user
+func sshDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh")
+}
+
+// sftpAuth returns the key based authentication methods for a connection.
+// The passed identity file is used when set, otherwise the default
+// identity files and a running agent are tried.  The returned agent
+// connection is nil when no agent is used.
+func sftpAuth(identity string) ([]ssh.AuthMethod, net.Conn, error) {
+	var files []string           // identity files
+	var signers []ssh.Signer     // loaded keys
+	var methods []ssh.AuthMethod // auth methods
+	var agentConn net.Conn       // agent connection
+
+	// find identities
+	if identity != "" {
+		files = []string{identity}
+	} else {
+		for _, name := range sftpIdentities {
+			files = append(files, filepath.Join(sshDir(), name))
+		}
+	}
+
+	// load keys
+	for _, file := range files {
+		var buf []byte        // key file contents
+		var signer ssh.Signer // parsed key
+		var err error         // general error holder
+		if buf, err = ioutil.ReadFile(file); err != nil {
+			if identity == "" && os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		if signer, err = ssh.ParsePrivateKey(buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse identity %s: %s", file, err.Error())
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	// use agent keys when not given an identity
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" && identity == "" {
+		var err error // general error holder
+		if agentConn, err = net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		}
+	}
+
+	// need at least one
+	if len(methods) == 0 {
+		return nil, nil, ErrSFTPMissingAuth
+	}
+
+	// return methods
+	return methods, agentConn, nil
+}
+
+// open parses an sftp uri and returns a session and the remote file path.
+// Host keys are always verified against a known_hosts file.
+func (ss *sftpStore) open(name string) (*sftpConn, string, error) {
+	var u *url.URL                   // parsed url
+	var config *ssh.ClientConfig     // ssh config
+	var hostKeys ssh.HostKeyCallback // host key verification
+	var conn *ssh.Client             // ssh connection
+	var client *sftp.Client          // sftp session
+	var agentConn net.Conn           // agent connection
+	var username, host, hosts string // connection details
+	var err error                    // general error holder
+
+	// parse the sftp path
+	if u, err = parseURI(name); err != nil {
+		return nil, "", err
+	}
+	if u.Hostname() == "" || path.Base(u.Path) == "/" || u.Path == "" {
+		return nil, "", ErrSFTPMissingHostPath
+	}
+
+	// get user
+	if username = u.User.Username(); username == "" {
+		var cu *user.User // current user
+		if cu, err = user.Current(); err != nil {
+			return nil, "", err
+		}
+		username = cu.Username
+	}
+
+	// get host
+	if host = u.Host; u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), sftpDefaultPort)
+	}
+
+	// load known hosts
+	if hosts = u.Query().Get("known_hosts"); hosts == "" {
+		hosts = filepath.Join(sshDir(), "known_hosts")
+	}
+	if hostKeys, err = knownhosts.New(hosts); err != nil {
+		return nil, "", err
+	}
+
+	// build config
+	config = &ssh.ClientConfig{
+		User:            username,
+		HostKeyCallback: hostKeys,
+		Timeout:         sftpTimeout,
+	}
+	if config.Auth, agentConn, err = sftpAuth(u.Query().Get("identity")); err != nil {
+		return nil, "", err
+	}
+
+	// connect - the agent is only needed during authentication
+	conn, err = ssh.Dial("tcp", host, config)
+	if agentConn != nil {
+		agentConn.Close()
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if client, err = sftp.NewClient(conn); err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+
+	// return session and path
+	return &sftpConn{Client: client, conn: conn}, u.Path, nil
+}
+
+// Put writes a file that is only accessible by the remote user.  Data is
+// written to a temporary file renamed over the destination so readers
+// never see a partial file.  Servers without the posix rename extension
+// can not rename over an existing file so the destination is first renamed
+// aside and only removed once the new file is in place, which leaves a short
+// window where the file does not exist.  The previous file is moved back
+// when the new file can not be renamed into place.
+func (ss *sftpStore) Put(name string, data []byte) error {
+	var sc *sftpConn        // sftp session
+	var p, temp, old string // file paths
+	var out *sftp.File      // temporary file
+	var moved bool          // destination renamed aside
+	var err error           // general error holder
+
+	// init
+	if sc, p, err = ss.open(name); err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	// ensure the directory exists
+	if err = sc.MkdirAll(path.Dir(p)); err != nil {
+		return err
+	}
+
+	// write temporary file next to the destination
+	temp = path.Join(path.Dir(p), fmt.Sprintf(".%s.%d.tmp", path.Base(p), time.Now().UnixNano()))
+	if out, err = sc.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL); err != nil {
+		return err
+	}
+	if err = out.Chmod(0600); err == nil {
+		_, err = out.Write(data)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		sc.Remove(temp)
+		return err
+	}
+
+	// replace destination in one step when supported
+	if _, ok := sc.HasExtension(sftpPosixRename); ok {
+		if err = sc.PosixRename(temp, p); err != nil {
+			sc.Remove(temp)
+			return err
+		}
+		return nil
+	}
+
+	// otherwise move the destination aside
+	old = path.Join(path.Dir(p), fmt.Sprintf(".%s.%d.old", path.Base(p), time.Now().UnixNano()))
+	if err = sc.Rename(p, old); err != nil && !os.IsNotExist(err) {
+		sc.Remove(temp)
+		return err
+	}
+	moved = err == nil
+
+	// rename the new file into place and restore the previous file on failure
+	if err = sc.Rename(temp, p); err != nil {
+		if moved {
+			sc.Rename(old, p)
+		}
+		sc.Remove(temp)
+		return err
+	}
+	if moved {
+		sc.Remove(old)
+	}
+
+	// all done
+	return nil
+}
+
+// Get reads a file
+func (ss *sftpStore) Get(name string) ([]byte, error) {
+	var sc *sftpConn  // sftp session
+	var p string      // file path
+	var in *sftp.File // remote file
+	var err error     // general error holder
+
+	// init
+	if sc, p, err = ss.open(name); err != nil {
+		return nil, err
+	}
+	defer sc.Close()
+
+	// read file
+	if in, err = sc.Open(p); err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return ioutil.ReadAll(in)
+}
+
+// List returns remote files matching the glob pattern
+// in the file path along with their signature files
+func (ss *sftpStore) List(pattern string) ([]*BackupInfo, error) {
+	var sc *sftpConn         // sftp session
+	var p string             // pattern path
+	var names, sigs []string // matched names
+	var out []*BackupInfo    // found files
+	var err error            // general error holder
+
+	// init
+	if sc, p, err = ss.open(pattern); err != nil {
+		return nil, err
+	}
+	defer sc.Close()
+
+	// find matching files and signatures
+	if names, err = sc.Glob(p); err != nil {
+		return nil, err
+	}
+	if sigs, err = sc.Glob(p + sigSuffix); err != nil {
+		return nil, err
+	}
+
+	// stat files
+	seen := make(map[string]bool, len(names)+len(sigs))
+	for _, name := range append(names, sigs...) {
+		var fi os.FileInfo // file info
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if fi, err = sc.Stat(name); err != nil {
+			return nil, err
+		}
+		if fi.IsDir() {
+			continue
+		}
+		out = append(out, fileInfo(replacePath(pattern, name), fi))
+	}
+
+	// return files
+	return out, nil
+}
+
+// Delete removes a file
+func (ss *sftpStore) Delete(name string) error {
+	var sc *sftpConn // sftp session
+	var p string     // file path
+	var err error    // general error holder
+
+	// init
+	if sc, p, err = ss.open(name); err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	// remove file
+	if err = sc.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Stat returns file details
+func (ss *sftpStore) Stat(name string) (*BackupInfo, error) {
+	var sc *sftpConn   // sftp session
+	var p string       // file path
+	var fi os.FileInfo // file info
+	var err error      // general error holder
+
+	// init
+	if sc, p, err = ss.open(name); err != nil {
+		return nil, err
+	}
+	defer sc.Close()
+
+	// stat file
+	if fi, err = sc.Stat(p); err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+
+	// return details
+	return fileInfo(name, fi), nil
+}
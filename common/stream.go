@@ -0,0 +1,109 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// StdioName is the location reading from stdin and writing to stdout
+const StdioName = "-"
+
+// ErrMissingStreamSignature is returned when reading a stream
+// without an embedded signature or separate signature location
+var ErrMissingStreamSignature = errors.New("stream does not contain a signature " +
+	"and no signature location was given")
+
+// streamMagic prefixes streams carrying an embedded signature
+var streamMagic = []byte("consul-backinator/stream\n")
+
+// IsStdio checks if a location refers to stdin or stdout
+func IsStdio(location string) bool {
+	return location == StdioName
+}
+
+// writeStream writes data to stdout preceded by the signature
+// unless the signature is written to a separate location
+func writeStream(sigDest string, raw, sig []byte) error {
+	var store Store       // signature backend
+	var buf *bytes.Buffer // stream header
+	var size [4]byte      // signature length
+	var err error         // general error holder
+
+	// write signature separately when requested
+	if sigDest != "" {
+		if store, err = openStore(sigDest); err != nil {
+			return err
+		}
+		if err = store.Put(sigDest, sig); err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(raw)
+		return err
+	}
+
+	// embed signature ahead of the data
+	buf = new(bytes.Buffer)
+	buf.Write(streamMagic)
+	binary.BigEndian.PutUint32(size[:], uint32(len(sig)))
+	buf.Write(size[:])
+	buf.Write(sig)
+	if _, err = os.Stdout.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(raw)
+
+	// return write error
+	return err
+}
+
+// readStream reads data from stdin and returns the data and the
+// signature read from the passed location or embedded in the stream
+func readStream(sigSrc string) ([]byte, []byte, error) {
+	var store Store     // signature backend
+	var raw, sig []byte // stream data and signature
+	var err error       // general error holder
+
+	// read stream
+	if raw, err = ioutil.ReadAll(os.Stdin); err != nil {
+		return nil, nil, err
+	}
+
+	// read signature separately when requested
+	if sigSrc != "" {
+		if store, err = openStore(sigSrc); err != nil {
+			return nil, nil, err
+		}
+		if sig, err = store.Get(sigSrc); err != nil {
+			return nil, nil, err
+		}
+		return raw, sig, nil
+	}
+
+	// split embedded signature
+	return splitStream(raw)
+}
+
+// splitStream returns the data and signature of a stream with an embedded signature
+func splitStream(stream []byte) ([]byte, []byte, error) {
+	var size uint32 // signature length
+
+	// check header
+	if !bytes.HasPrefix(stream, streamMagic) {
+		return nil, nil, ErrMissingStreamSignature
+	}
+	stream = stream[len(streamMagic):]
+	if len(stream) < 4 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	size, stream = binary.BigEndian.Uint32(stream), stream[4:]
+	if uint64(len(stream)) < uint64(size) {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+
+	// return data and signature
+	return stream[size:], stream[:size], nil
+}
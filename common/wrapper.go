@@ -1,39 +1,115 @@
 package common
 
-// WriteData writes an encrypted/compressed object and signature
-// to a local file or s3 datastore
-func WriteData(dest, key string, data []byte) error {
-	var info *s3Info // s3 info struct
-	var err error    // general error holder
-
-	// basic check
-	if isS3(dest) {
-		// parse destination as s3 uri and validate
-		if info, err = parseS3URI(dest); err != nil {
-			return err
-		}
-		// attempt to write to s3 source
-		return info.write(key, data)
-	}
-	// still going ... attempt file
-	return writeFile(dest, key, data)
+import "bytes"
+
+// WriteData writes an encrypted/compressed object and signature to a local
+// file, any registered storage backend or stdout.  The signature is written
+// next to the object unless a separate signature location is passed.
+func WriteData(dest, sigDest string, keys *Keys, data []byte) error {
+	var store Store            // storage backend
+	var buf, sig *bytes.Buffer // data and signature buffers
+	var err error              // general error holder
+
+	// encrypt/compress data
+	buf = new(bytes.Buffer)
+	if err = writeBytes(buf, keys, data); err != nil {
+		return err
+	}
+
+	// calculate data checksum
+	sig = new(bytes.Buffer)
+	if err = writeChecksum(sig, keys, buf.Bytes(), data); err != nil {
+		return err
+	}
+
+	// stream to stdout
+	if IsStdio(dest) {
+		return writeStream(sigDest, buf.Bytes(), sig.Bytes())
+	}
+
+	// find backend
+	if store, err = openStore(dest); err != nil {
+		return err
+	}
+	if sigDest == "" {
+		sigDest = signatureName(dest)
+	}
+
+	// write data and signature
+	if err = store.Put(dest, buf.Bytes()); err != nil {
+		return err
+	}
+	if store, err = openStore(sigDest); err != nil {
+		return err
+	}
+	return store.Put(sigDest, sig.Bytes())
+}
+
+// ReadData reads an encrypted/compressed object from a local file, any
+// registered storage backend or stdin and validates checksums.  The signature
+// is read from next to the object unless a separate location is passed.
+func ReadData(src, sigSrc string, keys *Keys) ([]byte, error) {
+	var raw, sig []byte // object data and signature
+	var err error       // general error holder
+
+	// read data and signature
+	if raw, sig, err = readRaw(src, sigSrc); err != nil {
+		return nil, err
+	}
+
+	// validate and decode
+	return readSigned(raw, sig, keys)
 }
 
-// ReadData reads an encrypted/compressed file or
-// S3 datastore object and validates checksums
-func ReadData(src, key string) ([]byte, error) {
-	var info *s3Info // s3 info struct
-	var err error    // general error holder
-
-	// basic check
-	if isS3(src) {
-		// parse source as s3 uri and validate
-		if info, err = parseS3URI(src); err != nil {
-			return nil, err
-		}
-		// attempt to write to s3 source
-		return info.read(key)
-	}
-	// still going ... attempt file
-	return readFile(src, key)
+// VerifySignature validates the public key signature of an encrypted object
+// without decrypting it so only the public key of the signer is needed
+func VerifySignature(src, sigSrc string, keys *Keys) error {
+	var raw, sig []byte // object data and signature
+	var err error       // general error holder
+
+	// read data and signature
+	if raw, sig, err = readRaw(src, sigSrc); err != nil {
+		return err
+	}
+
+	// only public key signatures cover the encrypted data
+	if !isPublicSignature(sig) {
+		return ErrNotPublicSignature
+	}
+	return verifyRaw(keys.Verifier, raw, sig)
+}
+
+// readRaw returns the encrypted data and signature of an object in a local
+// file, any registered storage backend or stdin without validating them
+func readRaw(src, sigSrc string) ([]byte, []byte, error) {
+	var store Store     // storage backend
+	var raw, sig []byte // object data and signature
+	var err error       // general error holder
+
+	// read stream from stdin
+	if IsStdio(src) {
+		return readStream(sigSrc)
+	}
+
+	// find backend
+	if store, err = openStore(src); err != nil {
+		return nil, nil, err
+	}
+	if sigSrc == "" {
+		sigSrc = signatureName(src)
+	}
+
+	// read data and signature
+	if raw, err = store.Get(src); err != nil {
+		return nil, nil, err
+	}
+	if store, err = openStore(sigSrc); err != nil {
+		return nil, nil, err
+	}
+	if sig, err = store.Get(sigSrc); err != nil {
+		return nil, nil, err
+	}
+
+	// return both
+	return raw, sig, nil
 }
@@ -5,9 +5,11 @@ go 1.15
 replace github.com/Sirupsen/logrus => github.com/sirupsen/logrus v1.7.0
 
 require (
-	cloud.google.com/go v0.24.1-0.20180628163445-75763d24f380 // indirect
+	cloud.google.com/go/storage v1.10.0
+	filippo.io/age v1.0.0
 	github.com/Azure/azure-sdk-for-go v17.4.0+incompatible // indirect
-	github.com/Azure/go-autorest v10.11.4+incompatible // indirect
+	github.com/Azure/azure-storage-blob-go v0.8.0
+	github.com/Azure/go-autorest v10.11.4+incompatible
 	github.com/Sirupsen/logrus v0.0.0-00010101000000-000000000000 // indirect
 	github.com/aws/aws-sdk-go v1.14.14
 	github.com/bgentry/speakeasy v0.1.0 // indirect
@@ -15,7 +17,6 @@ require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
 	github.com/digitalocean/godo v1.3.1-0.20180606193730-a3505618b6f4 // indirect
 	github.com/go-ini/ini v1.37.1-0.20180615003539-cec2bdc49009 // indirect
-	github.com/golang/protobuf v0.0.0-20160829194233-1f49d83d9aa0 // indirect
 	github.com/google/go-querystring v0.0.0-20170111101155-53e6ce116135 // indirect
 	github.com/gophercloud/gophercloud v0.0.0-20180626021939-19abc56a8cd8 // indirect
 	github.com/hashicorp/consul/api v1.8.0
@@ -26,13 +27,15 @@ require (
 	github.com/joyent/triton-go v0.0.0-20180628001255-830d2b111e62 // indirect
 	github.com/mitchellh/cli v1.1.0
 	github.com/nicolai86/scaleway-sdk v1.10.2-0.20170917185750-33df10cad9ff // indirect
+	github.com/pkg/sftp v1.13.4
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/renier/xmlrpc v0.0.0-20170708154548-ce4a1a486c03 // indirect
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/softlayer/softlayer-go v0.0.0-20180627132442-3aaf70665e74 // indirect
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/testify v1.7.0
 	github.com/tent/http-link-go v0.0.0-20130702225549-ac974c61c2f9 // indirect
 	github.com/vmware/govmomi v0.17.1 // indirect
 	github.com/vmware/vic v1.5.0-dev.0.20180628012636-fddf519e4fb8 // indirect
-	golang.org/x/oauth2 v0.0.0-20170313201147-1611bb46e67a // indirect
-	google.golang.org/api v0.0.0-20170125213714-dfa61ae24628 // indirect
-	google.golang.org/appengine v1.0.1-0.20161115221414-ca59ef35f409 // indirect
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
+	google.golang.org/api v0.28.0
 )
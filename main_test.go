@@ -1,19 +1,49 @@
 package main_test
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"io/ioutil"
 	stdLog "log"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"filippo.io/age"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil"
 	"github.com/mitchellh/cli"
+	"github.com/pkg/sftp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 
 	"github.com/myENA/consul-backinator/command/backup"
+	"github.com/myENA/consul-backinator/command/diff"
+	"github.com/myENA/consul-backinator/command/list"
+	"github.com/myENA/consul-backinator/command/prune"
 	"github.com/myENA/consul-backinator/command/restore"
+	"github.com/myENA/consul-backinator/command/rollback"
+	"github.com/myENA/consul-backinator/command/schedule"
+	"github.com/myENA/consul-backinator/command/verify"
+	"github.com/myENA/consul-backinator/command/watch"
+	"github.com/myENA/consul-backinator/common"
+	"github.com/myENA/consul-backinator/common/acl"
 )
 
 const (
@@ -21,6 +51,7 @@ const (
 	MySecretKey    = "CorrectHorseBatteryStaple"
 	appName        = "consul-backinator" // normally from version.go
 	appVersion     = "test"              // normally from version.go
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IfsuFq2UVErCz4I6tq/K1SZFTOBBZOQ/yuZnwQrNSy1wf3EXeA=="
 )
 
 type BackinatorTestSuite struct {
@@ -30,10 +61,376 @@ type BackinatorTestSuite struct {
 	TestSourceClient            *api.Client
 	TestSourceClientConfig      *api.Config
 	TestACLEntry                *api.ACLEntry
+	TestACLEmptyEntry           *api.ACLEntry
+	TestACLPolicy               *api.ACLPolicy
+	TestACLRole                 *api.ACLRole
+	TestACLToken                *api.ACLToken
 	TestPreparedQueryDefinition *api.PreparedQueryDefinition
 	TestKeyFile                 string
 	TestACLFile                 string
 	TestQueryFile               string
+	TestConfigFile              string
+	TestIntentionFile           string
+	TestBundleFile              string
+	TestIncrementFile           string
+	TestIncrementFile2          string
+	TestWatchFile               string
+	TestScheduleDir             string
+	TestLockFile                string
+	TestPruneDir                string
+	TestAgeFile                 string
+	TestIdentityFile            string
+	TestRecipient               string
+	TestSignedFile              string
+	TestSignKeyFile             string
+	TestVerifyKeyFile           string
+}
+
+// memStore is an in-memory storage backend
+type memStore struct {
+	sync.Mutex
+	objects map[string]*common.BackupInfo
+	data    map[string][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		objects: make(map[string]*common.BackupInfo),
+		data:    make(map[string][]byte),
+	}
+}
+
+func (ms *memStore) Put(name string, data []byte) error {
+	ms.Lock()
+	defer ms.Unlock()
+	// order objects by write even within the clock resolution
+	modified := time.Now()
+	for _, info := range ms.objects {
+		if !modified.After(info.Modified) {
+			modified = info.Modified.Add(time.Millisecond)
+		}
+	}
+	ms.objects[name] = &common.BackupInfo{Name: name, Size: int64(len(data)), Modified: modified}
+	ms.data[name] = data
+	return nil
+}
+
+func (ms *memStore) Get(name string) ([]byte, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	if data, ok := ms.data[name]; ok {
+		return data, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (ms *memStore) List(pattern string) ([]*common.BackupInfo, error) {
+	var out []*common.BackupInfo
+	ms.Lock()
+	defer ms.Unlock()
+	for name, info := range ms.objects {
+		if ok, _ := path.Match(pattern, strings.TrimSuffix(name, ".sig")); ok {
+			copied := *info
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (ms *memStore) Delete(name string) error {
+	ms.Lock()
+	defer ms.Unlock()
+	delete(ms.objects, name)
+	delete(ms.data, name)
+	return nil
+}
+
+func (ms *memStore) Stat(name string) (*common.BackupInfo, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	if info, ok := ms.objects[name]; ok {
+		copied := *info
+		return &copied, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// fakeGCS is a minimal Google Cloud Storage JSON API server
+type fakeGCS struct {
+	*memStore
+	server *httptest.Server
+}
+
+func newFakeGCS() *fakeGCS {
+	f := &fakeGCS{memStore: newMemStore()}
+	f.server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeGCS) resource(bucket string, info *common.BackupInfo) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":    "storage#object",
+		"bucket":  bucket,
+		"name":    strings.TrimPrefix(info.Name, bucket+"/"),
+		"size":    strconv.FormatInt(info.Size, 10),
+		"updated": info.Modified.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var parts []string // path parts
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/"):
+		// multipart upload of metadata and media
+		var meta struct{ Name string }
+		var data []byte
+		bucket := strings.Split(strings.TrimPrefix(r.URL.Path, "/upload/storage/v1/b/"), "/")[0]
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for i := 0; i < 2; i++ {
+			part, err := mr.NextPart()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if i == 0 {
+				json.NewDecoder(part).Decode(&meta)
+			} else {
+				data, _ = ioutil.ReadAll(part)
+			}
+		}
+		f.Put(bucket+"/"+meta.Name, data)
+		info, _ := f.Stat(bucket + "/" + meta.Name)
+		json.NewEncoder(w).Encode(f.resource(bucket, info))
+	case strings.HasPrefix(r.URL.Path, "/storage/v1/b/"):
+		// object metadata, listing and deletion
+		parts = strings.SplitN(strings.TrimPrefix(r.URL.Path, "/storage/v1/b/"), "/", 3)
+		bucket := parts[0]
+		if len(parts) < 3 {
+			var items []map[string]interface{}
+			f.Lock()
+			for name, info := range f.objects {
+				if strings.HasPrefix(name, bucket+"/"+r.URL.Query().Get("prefix")) {
+					items = append(items, f.resource(bucket, info))
+				}
+			}
+			f.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"kind": "storage#objects", "items": items})
+			return
+		}
+		info, err := f.Stat(bucket + "/" + parts[2])
+		if err != nil {
+			http.Error(w, `{"error":{"code":404,"message":"Not Found"}}`, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			f.Delete(info.Name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(f.resource(bucket, info))
+	default:
+		// media download
+		data, err := f.Get(strings.TrimPrefix(r.URL.Path, "/"))
+		if err != nil {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+// fakeAzure is a minimal Azure Blob Storage server
+type fakeAzure struct {
+	*memStore
+	server *httptest.Server
+}
+
+func newFakeAzure() *fakeAzure {
+	f := &fakeAzure{memStore: newMemStore()}
+	f.server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var parts []string // path parts
+	w.Header().Set("x-ms-version", "2018-11-09")
+	// path is /account/container[/blob]
+	parts = strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	container := parts[1]
+	if len(parts) < 3 {
+		switch {
+		case r.Method == http.MethodPut:
+			// container creation
+			w.WriteHeader(http.StatusCreated)
+		case r.URL.Query().Get("comp") == "list":
+			// flat blob listing
+			prefix := container + "/" + r.URL.Query().Get("prefix")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="%s"><Blobs>`, container)
+			f.Lock()
+			for name, info := range f.objects {
+				if strings.HasPrefix(name, prefix) {
+					fmt.Fprintf(w, `<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified>`+
+						`<Content-Length>%d</Content-Length><BlobType>BlockBlob</BlobType></Properties></Blob>`,
+						strings.TrimPrefix(name, container+"/"), info.Modified.UTC().Format(http.TimeFormat), info.Size)
+				}
+			}
+			f.Unlock()
+			fmt.Fprint(w, `</Blobs><NextMarker/></EnumerationResults>`)
+		default:
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+		}
+		return
+	}
+	name := container + "/" + parts[2]
+	if r.Method == http.MethodPut {
+		// single request block blob upload
+		data, _ := ioutil.ReadAll(r.Body)
+		f.Put(name, data)
+		info, _ := f.Stat(name)
+		w.Header().Set("Last-Modified", info.Modified.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	info, err := f.Stat(name)
+	if err != nil {
+		w.Header().Set("x-ms-error-code", "BlobNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodDelete:
+		f.Delete(name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		// properties and download
+		data, _ := f.Get(name)
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		w.Header().Set("Last-Modified", info.Modified.UTC().Format(http.TimeFormat))
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	}
+}
+
+// fakeSFTP is a minimal SSH server offering the sftp subsystem
+// with a single authorized key and host key.  Files are served from
+// the local filesystem unless request handlers are passed.
+type fakeSFTP struct {
+	listener   net.Listener
+	identity   string
+	knownHosts string
+	handlers   *sftp.Handlers
+}
+
+// failRename fails renames of temporary files when enabled
+type failRename struct {
+	sftp.FileCmder
+	fail bool
+}
+
+func (f *failRename) Filecmd(r *sftp.Request) error {
+	if f.fail && r.Method == "Rename" && strings.HasSuffix(r.Filepath, ".tmp") {
+		return fmt.Errorf("rename failed")
+	}
+	return f.FileCmder.Filecmd(r)
+}
+
+func newFakeSFTP(dir string, handlers *sftp.Handlers) (*fakeSFTP, error) {
+	var f = &fakeSFTP{handlers: handlers}
+	var config = new(ssh.ServerConfig)
+	// host key
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		return nil, err
+	}
+	config.AddHostKey(hostSigner)
+	// client key
+	clientPub, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	if err != nil {
+		return nil, err
+	}
+	f.identity = filepath.Join(dir, "id_ed25519")
+	if err = ioutil.WriteFile(f.identity, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	authorized, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		return nil, err
+	}
+	config.PublicKeyCallback = func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+		if string(key.Marshal()) == string(authorized.Marshal()) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unknown public key")
+	}
+	// listen and trust the host key
+	if f.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	f.knownHosts = filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{f.listener.Addr().String()}, hostSigner.PublicKey())
+	if err = ioutil.WriteFile(f.knownHosts, []byte(line+"\n"), 0600); err != nil {
+		f.listener.Close()
+		return nil, err
+	}
+	go f.serve(config)
+	return f, nil
+}
+
+func (f *fakeSFTP) serve(config *ssh.ServerConfig) {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			for nc := range chans {
+				if nc.ChannelType() != "session" {
+					nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+					continue
+				}
+				ch, chReqs, err := nc.Accept()
+				if err != nil {
+					continue
+				}
+				go func() {
+					for req := range chReqs {
+						ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+						req.Reply(ok, nil)
+						if ok {
+							go func() {
+								if f.handlers != nil {
+									sftp.NewRequestServer(ch, *f.handlers).Serve()
+								} else if server, err := sftp.NewServer(ch); err == nil {
+									server.Serve()
+								}
+								ch.Close()
+							}()
+						}
+					}
+				}()
+			}
+		}()
+	}
 }
 
 func mktemp(prefix string) string {
@@ -100,6 +497,44 @@ func (suite *BackinatorTestSuite) SetupSuite() {
 	assert.NoError(suite.T(), err, "api acl operation returned error")
 	assert.NotEmpty(suite.T(), aclID, "api acl operation returned empty")
 
+	// push a legacy client token without rules
+	suite.TestACLEmptyEntry = &api.ACLEntry{
+		Name: "myEmptyACL",
+		Type: api.ACLClientType,
+	}
+	suite.TestACLEmptyEntry.ID, _, err = suite.TestSourceClient.ACL().Create(suite.TestACLEmptyEntry, nil)
+
+	// check return
+	assert.NoError(suite.T(), err, "api acl operation returned error")
+
+	// push a custom policy
+	suite.TestACLPolicy, _, err = suite.TestSourceClient.ACL().PolicyCreate(&api.ACLPolicy{
+		Name:  "myCustomPolicy",
+		Rules: `key_prefix "foo/" { policy = "write" }`,
+	}, nil)
+
+	// check return
+	assert.NoError(suite.T(), err, "api policy operation returned error")
+
+	// push a custom role linked to the policy
+	suite.TestACLRole, _, err = suite.TestSourceClient.ACL().RoleCreate(&api.ACLRole{
+		Name:     "myCustomRole",
+		Policies: []*api.ACLRolePolicyLink{{ID: suite.TestACLPolicy.ID}},
+	}, nil)
+
+	// check return
+	assert.NoError(suite.T(), err, "api role operation returned error")
+
+	// push a custom token linked to the policy and role
+	suite.TestACLToken, _, err = suite.TestSourceClient.ACL().TokenCreate(&api.ACLToken{
+		Description: "myCustomToken",
+		Policies:    []*api.ACLTokenPolicyLink{{ID: suite.TestACLPolicy.ID}},
+		Roles:       []*api.ACLTokenRoleLink{{ID: suite.TestACLRole.ID}},
+	}, nil)
+
+	// check return
+	assert.NoError(suite.T(), err, "api token operation returned error")
+
 	// populate dummy prepared query
 	suite.TestPreparedQueryDefinition = &api.PreparedQueryDefinition{
 		Name: "myCustomQuery",
@@ -118,6 +553,39 @@ func (suite *BackinatorTestSuite) SetupSuite() {
 	assert.NoError(suite.T(), err, "api query operation returned error")
 	assert.NotEmpty(suite.T(), queryID, "api query operation returned empty")
 
+	// push service defaults
+	_, _, err = suite.TestSourceClient.ConfigEntries().Set(&api.ServiceConfigEntry{
+		Kind:     api.ServiceDefaults,
+		Name:     "testService",
+		Protocol: "http",
+	}, nil)
+
+	// check return
+	assert.NoError(suite.T(), err, "api config entry operation returned error")
+
+	// push a router that depends on the http protocol set above
+	_, _, err = suite.TestSourceClient.ConfigEntries().Set(&api.ServiceRouterConfigEntry{
+		Kind: api.ServiceRouter,
+		Name: "testService",
+		Routes: []api.ServiceRoute{{
+			Match: &api.ServiceRouteMatch{HTTP: &api.ServiceRouteHTTPMatch{PathPrefix: "/admin"}},
+		}},
+	}, nil)
+
+	// check return
+	assert.NoError(suite.T(), err, "api config entry operation returned error")
+
+	// push an intention
+	_, _, err = suite.TestSourceClient.Connect().IntentionCreate(&api.Intention{
+		SourceName:      "web",
+		DestinationName: "testService",
+		SourceType:      api.IntentionSourceConsul,
+		Action:          api.IntentionActionAllow,
+	}, nil)
+
+	// check return
+	assert.NoError(suite.T(), err, "api intention operation returned error")
+
 	// populate source kv data
 	suite.TestSource.SetKVString(suite.T(), "key1", "value1")
 	suite.TestSource.SetKVString(suite.T(), "folder1/key2", "value2")
@@ -134,6 +602,56 @@ func (suite *BackinatorTestSuite) SetupSuite() {
 	suite.TestACLFile = mktemp(appName + ".acls")
 	suite.TestQueryFile = mktemp(appName + ".pqs")
 	suite.TestKeyFile = mktemp(appName + ".bak")
+	suite.TestConfigFile = mktemp(appName + ".configs")
+	suite.TestIntentionFile = mktemp(appName + ".intentions")
+	suite.TestBundleFile = mktemp(appName + ".bundle")
+	suite.TestIncrementFile = mktemp(appName + ".inc1")
+	suite.TestIncrementFile2 = mktemp(appName + ".inc2")
+	suite.TestWatchFile = mktemp(appName + ".watch")
+	if suite.TestScheduleDir, err = ioutil.TempDir(os.TempDir(), appName+".schedule"); err != nil {
+		panic(err.Error())
+	}
+	suite.TestLockFile = mktemp(appName + ".lock")
+	if suite.TestPruneDir, err = ioutil.TempDir(os.TempDir(), appName+".prune"); err != nil {
+		panic(err.Error())
+	}
+	suite.TestAgeFile = mktemp(appName + ".age")
+	suite.TestIdentityFile = mktemp(appName + ".identity")
+
+	// generate public key encryption identity
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		panic(err.Error())
+	}
+	if err = ioutil.WriteFile(suite.TestIdentityFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		panic(err.Error())
+	}
+	suite.TestRecipient = identity.Recipient().String()
+
+	// generate signing keys
+	suite.TestSignedFile = mktemp(appName + ".signed")
+	suite.TestSignKeyFile = mktemp(appName + ".sign")
+	suite.TestVerifyKeyFile = mktemp(appName + ".verify")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err.Error())
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		panic(err.Error())
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		panic(err.Error())
+	}
+	if err = ioutil.WriteFile(suite.TestSignKeyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		panic(err.Error())
+	}
+	if err = ioutil.WriteFile(suite.TestVerifyKeyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0600); err != nil {
+		panic(err.Error())
+	}
 }
 
 func (suite *BackinatorTestSuite) TearDownSuite() {
@@ -148,6 +666,37 @@ func (suite *BackinatorTestSuite) TearDownSuite() {
 	os.Remove(suite.TestACLFile + ".sig")
 	os.Remove(suite.TestQueryFile)
 	os.Remove(suite.TestQueryFile + ".sig")
+	os.Remove(suite.TestConfigFile)
+	os.Remove(suite.TestConfigFile + ".sig")
+	os.Remove(suite.TestIntentionFile)
+	os.Remove(suite.TestIntentionFile + ".sig")
+	os.Remove(suite.TestBundleFile)
+	os.Remove(suite.TestBundleFile + ".sig")
+	os.Remove(suite.TestIncrementFile)
+	os.Remove(suite.TestIncrementFile + ".sig")
+	os.Remove(suite.TestIncrementFile2)
+	os.Remove(suite.TestIncrementFile2 + ".sig")
+	os.RemoveAll(suite.TestScheduleDir)
+	os.Remove(suite.TestLockFile)
+	os.RemoveAll(suite.TestPruneDir)
+	os.Remove(suite.TestLockFile + ".sig")
+	if names, err := filepath.Glob(suite.TestWatchFile + "*"); err == nil {
+		for _, name := range names {
+			os.Remove(name)
+		}
+	}
+	os.Remove(suite.TestAgeFile)
+	os.Remove(suite.TestAgeFile + ".sig")
+	os.Remove(suite.TestIdentityFile)
+	os.Remove(suite.TestSignedFile)
+	os.Remove(suite.TestSignedFile + ".sig")
+	os.Remove(suite.TestSignKeyFile)
+	os.Remove(suite.TestVerifyKeyFile)
+	for _, name := range []string{suite.TestKeyFile, suite.TestBundleFile,
+		suite.TestAgeFile, suite.TestSignedFile} {
+		os.Remove(name + ".pre-restore")
+		os.Remove(name + ".pre-restore.sig")
+	}
 	suite.T().Log("Done!")
 }
 
@@ -168,6 +717,10 @@ func (suite *BackinatorTestSuite) Test01Backup() {
 		suite.TestACLFile,
 		"-queries",
 		suite.TestQueryFile,
+		"-configs",
+		suite.TestConfigFile,
+		"-intentions",
+		suite.TestIntentionFile,
 		"-addr",
 		suite.TestSource.HTTPAddr,
 		"-dc",
@@ -208,6 +761,10 @@ func (suite *BackinatorTestSuite) Test02Restore() {
 		suite.TestACLFile,
 		"-queries",
 		suite.TestQueryFile,
+		"-configs",
+		suite.TestConfigFile,
+		"-intentions",
+		suite.TestIntentionFile,
 		"-addr",
 		suite.TestTarget.HTTPAddr,
 		"-dc",
@@ -236,6 +793,2108 @@ func (suite *BackinatorTestSuite) Test03VerifyTarget() {
 	// it matches the original source
 }
 
+func (suite *BackinatorTestSuite) Test04VerifyTargetACLs() {
+	var client *api.Client    // target client
+	var config *api.Config    // target client config
+	var policy *api.ACLPolicy // restored policy
+	var role *api.ACLRole     // restored role
+	var token *api.ACLToken   // restored token
+	var err error             // error holder
+
+	// build target client
+	config = api.DefaultConfig()
+	config.Address = suite.TestTarget.HTTPAddr
+	config.Datacenter = suite.TestTarget.Config.Datacenter
+	config.Token = MyAwesomeToken
+	if client, err = api.NewClient(config); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	// check policy
+	policy, _, err = client.ACL().PolicyReadByName(suite.TestACLPolicy.Name, nil)
+	assert.NoError(suite.T(), err, "api policy operation returned error")
+	if assert.NotNil(suite.T(), policy, "policy not restored") {
+		assert.Equal(suite.T(), suite.TestACLPolicy.Rules, policy.Rules, "policy rules differ")
+	}
+
+	// check role
+	role, _, err = client.ACL().RoleReadByName(suite.TestACLRole.Name, nil)
+	assert.NoError(suite.T(), err, "api role operation returned error")
+	if assert.NotNil(suite.T(), role, "role not restored") && policy != nil {
+		assert.Equal(suite.T(), policy.ID, role.Policies[0].ID, "role policy link not restored")
+	}
+
+	// check token identifiers and links
+	token, _, err = client.ACL().TokenRead(suite.TestACLToken.AccessorID, nil)
+	if assert.NoError(suite.T(), err, "api token operation returned error") {
+		assert.Equal(suite.T(), suite.TestACLToken.SecretID, token.SecretID, "token secret differs")
+		if policy != nil && role != nil {
+			assert.Equal(suite.T(), policy.ID, token.Policies[0].ID, "token policy link not restored")
+			assert.Equal(suite.T(), role.ID, token.Roles[0].ID, "token role link not restored")
+		}
+	}
+
+	// a legacy client token without rules must not become a management token
+	entry, _, err := client.ACL().Info(suite.TestACLEmptyEntry.ID, nil)
+	if assert.NoError(suite.T(), err, "api acl operation returned error") &&
+		assert.NotNil(suite.T(), entry, "legacy token not restored") {
+		assert.Equal(suite.T(), api.ACLClientType, entry.Type, "legacy token type differs")
+	}
+}
+
+func (suite *BackinatorTestSuite) Test05VerifyTargetConfigEntries() {
+	var client *api.Client    // target client
+	var config *api.Config    // target client config
+	var entry api.ConfigEntry // restored entry
+	var err error             // error holder
+
+	// build target client
+	config = api.DefaultConfig()
+	config.Address = suite.TestTarget.HTTPAddr
+	config.Datacenter = suite.TestTarget.Config.Datacenter
+	config.Token = MyAwesomeToken
+	if client, err = api.NewClient(config); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	// check service defaults
+	entry, _, err = client.ConfigEntries().Get(api.ServiceDefaults, "testService", nil)
+	if assert.NoError(suite.T(), err, "api config entry operation returned error") {
+		assert.Equal(suite.T(), "http", entry.(*api.ServiceConfigEntry).Protocol, "protocol differs")
+	}
+
+	// check router
+	entry, _, err = client.ConfigEntries().Get(api.ServiceRouter, "testService", nil)
+	if assert.NoError(suite.T(), err, "api config entry operation returned error") {
+		assert.Len(suite.T(), entry.(*api.ServiceRouterConfigEntry).Routes, 1, "routes differ")
+	}
+}
+
+func (suite *BackinatorTestSuite) Test06VerifyTargetIntentions() {
+	var client *api.Client // target client
+	var config *api.Config // target client config
+	var ixn *api.Intention // restored intention
+	var err error          // error holder
+
+	// build target client
+	config = api.DefaultConfig()
+	config.Address = suite.TestTarget.HTTPAddr
+	config.Datacenter = suite.TestTarget.Config.Datacenter
+	config.Token = MyAwesomeToken
+	if client, err = api.NewClient(config); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	// check intention
+	ixn, _, err = client.Connect().IntentionGetExact("web", "testService", nil)
+	if assert.NoError(suite.T(), err, "api intention operation returned error") &&
+		assert.NotNil(suite.T(), ixn, "intention not restored") {
+		assert.Equal(suite.T(), api.IntentionActionAllow, ixn.Action, "intention action differs")
+	}
+}
+
+func (suite *BackinatorTestSuite) Test07BackupBundle() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"backup",
+		"-file",
+		suite.TestBundleFile,
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"all",
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"backup": func() (cli.Command, error) {
+			return &backup.Command{
+				Self:    "test-backup",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+}
+
+func (suite *BackinatorTestSuite) Test08RestoreBundle() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestBundleFile,
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"all",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+}
+
+func (suite *BackinatorTestSuite) Test09BackupRecipients() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"backup",
+		"-file",
+		suite.TestAgeFile,
+		"-key",
+		MySecretKey,
+		"-recipients",
+		suite.TestRecipient,
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"backup": func() (cli.Command, error) {
+			return &backup.Command{
+				Self:    "test-backup",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+}
+
+func (suite *BackinatorTestSuite) Test10RestoreIdentity() {
+	var c *cli.CLI    // cli object
+	var args []string // command arguments
+	var status int    // exit status
+	var err error     // error holder
+
+	// build restore arguments
+	args = []string{
+		"restore",
+		"-file",
+		suite.TestAgeFile,
+		"-key",
+		MySecretKey,
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+
+	// run restore with and without the identity file
+	for _, withIdentity := range []bool{false, true} {
+		// init and populate cli object
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = args
+		if withIdentity {
+			c.Args = append(c.Args, "-identity", suite.TestIdentityFile)
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"restore": func() (cli.Command, error) {
+				return &restore.Command{
+					Self: "test-restore",
+					Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		// run command
+		status, err = c.Run()
+
+		// check results
+		assert.NoError(suite.T(), err, "operation returned error")
+		if withIdentity {
+			assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+		} else {
+			assert.Equal(suite.T(), status, 1, "operation without identity exited zero")
+		}
+	}
+
+	// the snapshot should be encrypted to the identity rather than the passphrase
+	_, err = common.ReadData(suite.TestAgeFile+".pre-restore", "", common.NewKeys(MySecretKey))
+	assert.Equal(suite.T(), common.ErrMissingIdentity, err, "snapshot not encrypted to identity")
+	keys := common.NewKeys(MySecretKey)
+	assert.NoError(suite.T(), keys.AddIdentities(suite.TestIdentityFile), "failed to read identity")
+	_, err = common.ReadData(suite.TestAgeFile+".pre-restore", "", keys)
+	assert.NoError(suite.T(), err, "snapshot not readable with identity")
+}
+
+func (suite *BackinatorTestSuite) Test11BackupSigned() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"backup",
+		"-file",
+		suite.TestSignedFile,
+		"-recipients",
+		suite.TestRecipient,
+		"-sign-key",
+		suite.TestSignKeyFile,
+		"-bundle",
+		"kv",
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"backup": func() (cli.Command, error) {
+			return &backup.Command{
+				Self:    "test-backup",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+}
+
+func (suite *BackinatorTestSuite) Test12RestoreVerified() {
+	var c *cli.CLI    // cli object
+	var args []string // command arguments
+	var status int    // exit status
+	var err error     // error holder
+
+	// build restore arguments
+	args = []string{
+		"restore",
+		"-file",
+		suite.TestSignedFile,
+		"-identity",
+		suite.TestIdentityFile,
+		"-bundle",
+		"kv",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+
+	// run restore with and without the public key
+	for _, withKey := range []bool{false, true} {
+		// init and populate cli object
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = args
+		if withKey {
+			c.Args = append(c.Args, "-verify-key", suite.TestVerifyKeyFile)
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"restore": func() (cli.Command, error) {
+				return &restore.Command{
+					Self: "test-restore",
+					Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		// run command
+		status, err = c.Run()
+
+		// check results
+		assert.NoError(suite.T(), err, "operation returned error")
+		if withKey {
+			assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+		} else {
+			assert.Equal(suite.T(), status, 1, "operation without public key exited zero")
+		}
+	}
+}
+
+func (suite *BackinatorTestSuite) Test13Verify() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"verify",
+		"-file",
+		suite.TestBundleFile,
+		"-key",
+		MySecretKey,
+		"-json",
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"verify": func() (cli.Command, error) {
+			return &verify.Command{
+				Self: "test-verify",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// validate signatures with only the public key and no passphrase
+	for _, file := range []string{suite.TestSignedFile, suite.TestBundleFile} {
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = []string{
+			"verify",
+			"-file",
+			file,
+			"-verify-key",
+			suite.TestVerifyKeyFile,
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"verify": func() (cli.Command, error) {
+				return &verify.Command{
+					Self: "test-verify",
+					Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		status, err = c.Run()
+		assert.NoError(suite.T(), err, "operation returned error")
+		if file == suite.TestSignedFile {
+			assert.Equal(suite.T(), status, 0, "public key signature not validated")
+		} else {
+			assert.NotEqual(suite.T(), status, 0, "passphrase signature validated with public key")
+		}
+	}
+
+	// legacy tokens still waiting for an accessor are valid
+	legacyFile := mktemp(appName + ".legacy")
+	defer os.Remove(legacyFile)
+	defer os.Remove(legacyFile + ".sig")
+	data, err := json.Marshal(&acl.Snapshot{Tokens: []*acl.Token{{
+		ACLToken: &api.ACLToken{
+			SecretID:    "5b8a1e2c-1f0e-4d6b-9c3a-7e2f4a6d8b10",
+			Description: "pendingLegacyToken",
+		},
+		Legacy: true,
+		Type:   api.ACLClientType,
+	}}})
+	assert.NoError(suite.T(), err, "failed to encode acls")
+	assert.NoError(suite.T(), common.WriteData(legacyFile, "", common.NewKeys(MySecretKey), data),
+		"failed to write acls")
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"verify",
+		"-file",
+		legacyFile,
+		"-key",
+		MySecretKey,
+		"-acls",
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"verify": func() (cli.Command, error) {
+			return &verify.Command{
+				Self: "test-verify",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "legacy token reported as malformed")
+}
+
+func (suite *BackinatorTestSuite) Test14Diff() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"diff",
+		"-file",
+		suite.TestKeyFile,
+		"-key",
+		MySecretKey,
+		"-values",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"diff": func() (cli.Command, error) {
+			return &diff.Command{
+				Self: "test-diff",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+}
+
+func (suite *BackinatorTestSuite) Test15RestoreDryRun() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// add a key that a delete would remove
+	suite.TestTarget.SetKVString(suite.T(), "dryrun/key4", "value4")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestKeyFile,
+		"-key",
+		MySecretKey,
+		"-acls",
+		suite.TestACLFile,
+		"-queries",
+		suite.TestQueryFile,
+		"-configs",
+		suite.TestConfigFile,
+		"-intentions",
+		suite.TestIntentionFile,
+		"-delete",
+		"-dry-run",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	assert.Equal(suite.T(), "value4", suite.TestTarget.GetKVString(suite.T(), "dryrun/key4"),
+		"dry run modified target")
+}
+
+func (suite *BackinatorTestSuite) Test16RestoreAtomic() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestKeyFile,
+		"-key",
+		MySecretKey,
+		"-delete",
+		"-atomic",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self:    "test-restore",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// verify the tree was replaced in the target
+	assert.Equal(suite.T(), "value1", suite.TestTarget.GetKVString(suite.T(), "key1"),
+		"restored key differs")
+	assert.NotContains(suite.T(), suite.TestTarget.ListKV(suite.T(), ""), "dryrun/key4",
+		"deleted key still present")
+}
+
+func (suite *BackinatorTestSuite) Test17Rollback() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"rollback",
+		"-file",
+		suite.TestKeyFile + ".pre-restore",
+		"-key",
+		MySecretKey,
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"rollback": func() (cli.Command, error) {
+			return &rollback.Command{
+				Self: "test-rollback",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// the key deleted by the atomic restore should be back
+	assert.Equal(suite.T(), "value4", suite.TestTarget.GetKVString(suite.T(), "dryrun/key4"),
+		"rollback did not restore deleted key")
+
+	// the snapshot should record the version that wrote it
+	bundle, err := common.ReadBundle(suite.TestKeyFile+".pre-restore", "", common.NewKeys(MySecretKey))
+	if assert.NoError(suite.T(), err, "failed to read snapshot") {
+		assert.Equal(suite.T(), appVersion, bundle.Manifest.Version, "snapshot version differs")
+	}
+}
+
+func (suite *BackinatorTestSuite) Test18RestoreSkipExisting() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// change a key that is present in the backup
+	suite.TestTarget.SetKVString(suite.T(), "key1", "changed1")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestKeyFile,
+		"-key",
+		MySecretKey,
+		"-conflict",
+		"skip-existing",
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// the existing key should have been left alone
+	assert.Equal(suite.T(), "changed1", suite.TestTarget.GetKVString(suite.T(), "key1"),
+		"existing key overwritten")
+}
+
+func (suite *BackinatorTestSuite) Test19RestoreMirror() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// add a key that is not present in the backup
+	suite.TestTarget.SetKVString(suite.T(), "mirror/key5", "value5")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestKeyFile,
+		"-key",
+		MySecretKey,
+		"-mirror",
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// the tree should match the backup
+	assert.Equal(suite.T(), "value1", suite.TestTarget.GetKVString(suite.T(), "key1"),
+		"restored key differs")
+	assert.NotContains(suite.T(), suite.TestTarget.ListKV(suite.T(), ""), "mirror/key5",
+		"absent key still present")
+}
+
+func (suite *BackinatorTestSuite) Test20BackupIncremental() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// add a key after the bundle was taken
+	suite.TestSource.SetKVString(suite.T(), "increment/key6", "value6")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"backup",
+		"-file",
+		suite.TestIncrementFile,
+		"-key",
+		MySecretKey,
+		"-incremental",
+		suite.TestBundleFile,
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"backup": func() (cli.Command, error) {
+			return &backup.Command{
+				Self:    "test-backup",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// delete the key again
+	_, err = suite.TestSourceClient.KV().Delete("increment/key6", nil)
+	assert.NoError(suite.T(), err, "failed to delete source key")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"backup",
+		"-file",
+		suite.TestIncrementFile2,
+		"-key",
+		MySecretKey,
+		"-incremental",
+		suite.TestIncrementFile,
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"backup": func() (cli.Command, error) {
+			return &backup.Command{
+				Self:    "test-backup",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+}
+
+func (suite *BackinatorTestSuite) Test21RestoreIncremental() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestBundleFile,
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"kv",
+		"-increments",
+		suite.TestIncrementFile,
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// the key added by the first increment should be present
+	assert.Equal(suite.T(), "value6", suite.TestTarget.GetKVString(suite.T(), "increment/key6"),
+		"incremental key not restored")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestBundleFile,
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"kv",
+		"-increments",
+		suite.TestIncrementFile + "," + suite.TestIncrementFile2,
+		"-mirror",
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// the key deleted by the second increment should be gone
+	assert.NotContains(suite.T(), suite.TestTarget.ListKV(suite.T(), ""), "increment/key6",
+		"deleted key still present")
+}
+
+func (suite *BackinatorTestSuite) Test22Watch() {
+	var c *cli.CLI         // cli object
+	var status int         // exit status
+	var err error          // error holder
+	var done chan struct{} // completion signal
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"watch",
+		"-file",
+		suite.TestWatchFile,
+		"-key",
+		MySecretKey,
+		"-incremental",
+		"-debounce",
+		"1s",
+		"-wait",
+		"10s",
+		"-limit",
+		"2",
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"watch": func() (cli.Command, error) {
+			return &watch.Command{
+				Self:    "test-watch",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command in the background
+	done = make(chan struct{})
+	go func() {
+		status, err = c.Run()
+		close(done)
+	}()
+
+	// change a key once the initial backup is written
+	time.Sleep(2 * time.Second)
+	suite.TestSource.SetKVString(suite.T(), "watch/key7", "value7")
+
+	// wait for the increment
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		suite.T().Fatal("watch did not write an increment")
+	}
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// the full backup and one increment should be present
+	names, _ := filepath.Glob(suite.TestWatchFile + ".*Z")
+	assert.Len(suite.T(), names, 2, "increment not written")
+
+	// a restart should begin a new chain without replacing the existing one
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"watch",
+		"-file",
+		suite.TestWatchFile,
+		"-key",
+		MySecretKey,
+		"-incremental",
+		"-limit",
+		"1",
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"watch": func() (cli.Command, error) {
+			return &watch.Command{
+				Self:    "test-watch",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	restarted, _ := filepath.Glob(suite.TestWatchFile + ".*Z")
+	assert.Len(suite.T(), restarted, 3, "new chain not written")
+	assert.Subset(suite.T(), restarted, names, "existing chain replaced")
+}
+
+func (suite *BackinatorTestSuite) Test23Schedule() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// add a file the retention policy must not touch
+	unrelated := filepath.Join(suite.TestScheduleDir, "unrelated.txt")
+	assert.NoError(suite.T(), ioutil.WriteFile(unrelated, nil, 0600), "failed to write file")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"schedule",
+		"-cron",
+		"@every 1s",
+		"-file",
+		filepath.Join(suite.TestScheduleDir, "backup-{{.Datacenter}}-{{.Timestamp}}.bak"),
+		"-key",
+		MySecretKey,
+		"-keep-last",
+		"2",
+		"-limit",
+		"3",
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"schedule": func() (cli.Command, error) {
+			return &schedule.Command{
+				Self:    "test-schedule",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// only the two newest backups and the unrelated file should remain
+	names, _ := filepath.Glob(filepath.Join(suite.TestScheduleDir,
+		"backup-"+suite.TestSource.Config.Datacenter+"-*.bak"))
+	assert.Len(suite.T(), names, 2, "retention policy not applied")
+	assert.FileExists(suite.T(), unrelated, "unrelated file removed")
+}
+
+func (suite *BackinatorTestSuite) Test24WatchLock() {
+	var c *cli.CLI         // cli object
+	var status int         // exit status
+	var err error          // error holder
+	var lock *api.Lock     // competing lock
+	var done chan struct{} // completion signal
+
+	// hold the lock as another instance would
+	lock, err = suite.TestSourceClient.LockKey("backinator/leader")
+	assert.NoError(suite.T(), err, "failed to build lock")
+	_, err = lock.Lock(nil)
+	assert.NoError(suite.T(), err, "failed to acquire lock")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"watch",
+		"-file",
+		suite.TestLockFile,
+		"-key",
+		MySecretKey,
+		"-limit",
+		"1",
+		"-lock",
+		"backinator/leader",
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"watch": func() (cli.Command, error) {
+			return &watch.Command{
+				Self:    "test-watch",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command in the background
+	done = make(chan struct{})
+	go func() {
+		status, err = c.Run()
+		close(done)
+	}()
+
+	// nothing should be written while the lock is held elsewhere
+	time.Sleep(2 * time.Second)
+	if info, err := os.Stat(suite.TestLockFile); assert.NoError(suite.T(), err, "failed to stat file") {
+		assert.Zero(suite.T(), info.Size(), "backup written without the lock")
+	}
+
+	// release the lock and wait for the backup
+	assert.NoError(suite.T(), lock.Unlock(), "failed to release lock")
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		suite.T().Fatal("watch did not take over the lock")
+	}
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	if info, err := os.Stat(suite.TestLockFile); assert.NoError(suite.T(), err, "failed to stat file") {
+		assert.NotZero(suite.T(), info.Size(), "backup not written")
+	}
+}
+
+func (suite *BackinatorTestSuite) Test25Prune() {
+	var c *cli.CLI // cli object
+	var status int // exit status
+	var err error  // error holder
+
+	// write backups a day apart with signatures and an unrelated file
+	for i := 0; i < 5; i++ {
+		name := filepath.Join(suite.TestPruneDir, fmt.Sprintf("backup-%d.bak", i))
+		assert.NoError(suite.T(), ioutil.WriteFile(name, []byte("data"), 0600), "failed to write file")
+		assert.NoError(suite.T(), ioutil.WriteFile(name+".sig", []byte("sig"), 0600), "failed to write file")
+		modified := time.Now().Add(-time.Duration(i) * 24 * time.Hour)
+		assert.NoError(suite.T(), os.Chtimes(name, modified, modified), "failed to set time")
+	}
+	unrelated := filepath.Join(suite.TestPruneDir, "unrelated.txt")
+	assert.NoError(suite.T(), ioutil.WriteFile(unrelated, nil, 0600), "failed to write file")
+
+	// prune with and without a dry run
+	for _, dryRun := range []bool{true, false} {
+		// init and populate cli object
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = []string{
+			"prune",
+			"-path",
+			suite.TestPruneDir,
+			"-match",
+			"backup-*.bak",
+			"-keep-last",
+			"1",
+			"-keep-within",
+			"36h",
+		}
+		if dryRun {
+			c.Args = append(c.Args, "-dry-run")
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"prune": func() (cli.Command, error) {
+				return &prune.Command{
+					Self: "test-prune",
+					Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		// run command
+		status, err = c.Run()
+
+		// check results
+		assert.NoError(suite.T(), err, "operation returned error")
+		assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+		// a dry run removes nothing
+		names, _ := filepath.Glob(filepath.Join(suite.TestPruneDir, "backup-*"))
+		if dryRun {
+			assert.Len(suite.T(), names, 10, "dry run removed backups")
+			continue
+		}
+
+		// only the two backups within 36 hours and their signatures remain
+		assert.ElementsMatch(suite.T(), []string{
+			filepath.Join(suite.TestPruneDir, "backup-0.bak"),
+			filepath.Join(suite.TestPruneDir, "backup-0.bak.sig"),
+			filepath.Join(suite.TestPruneDir, "backup-1.bak"),
+			filepath.Join(suite.TestPruneDir, "backup-1.bak.sig"),
+		}, names, "retention policy not applied")
+		assert.FileExists(suite.T(), unrelated, "unrelated file removed")
+	}
+}
+
+func (suite *BackinatorTestSuite) Test26List() {
+	var c *cli.CLI      // cli object
+	var status int      // exit status
+	var err error       // error holder
+	var r, w *os.File   // stdout pipe
+	var stdout *os.File // original stdout
+	var out []byte      // captured output
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"list",
+		"-path",
+		filepath.Dir(suite.TestBundleFile),
+		"-match",
+		filepath.Base(suite.TestBundleFile),
+		"-key",
+		MySecretKey,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"list": func() (cli.Command, error) {
+			return &list.Command{
+				Self: "test-list",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// capture output
+	r, w, err = os.Pipe()
+	assert.NoError(suite.T(), err, "failed to create pipe")
+	stdout, os.Stdout = os.Stdout, w
+
+	// run command
+	status, err = c.Run()
+
+	// restore output
+	os.Stdout = stdout
+	w.Close()
+	out, _ = ioutil.ReadAll(r)
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// the bundle should be listed as signed with manifest details
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if assert.Len(suite.T(), lines, 2, "unexpected listing") {
+		fields := strings.Fields(lines[1])
+		if assert.Len(suite.T(), fields, 6, "unexpected listing") {
+			assert.Equal(suite.T(), "true", fields[2], "signature not found")
+			assert.Equal(suite.T(), suite.TestSource.Config.Datacenter, fields[3], "datacenter differs")
+			assert.Regexp(suite.T(), `^[1-9][0-9]*$`, fields[4], "key count missing")
+			assert.Equal(suite.T(), suite.TestBundleFile, fields[5], "name differs")
+		}
+	}
+}
+
+func (suite *BackinatorTestSuite) Test27Store() {
+	var c *cli.CLI      // cli object
+	var status int      // exit status
+	var err error       // error holder
+	var store *memStore // registered store
+
+	// register an in-memory backend
+	store = newMemStore()
+	common.RegisterStore("mem", store)
+
+	// write two backups to the backend
+	for _, name := range []string{"mem://test/backup-1.bak", "mem://test/backup-2.bak"} {
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = []string{
+			"backup",
+			"-file",
+			name,
+			"-key",
+			MySecretKey,
+			"-bundle",
+			"kv",
+			"-addr",
+			suite.TestSource.HTTPAddr,
+			"-dc",
+			suite.TestSource.Config.Datacenter,
+			"-token",
+			MyAwesomeToken,
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"backup": func() (cli.Command, error) {
+				return &backup.Command{
+					Self:    "test-backup",
+					Version: appVersion,
+					Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		status, err = c.Run()
+		assert.NoError(suite.T(), err, "operation returned error")
+		assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	}
+	assert.Len(suite.T(), store.objects, 4, "backups or signatures not written")
+
+	// verify the newest backup from the backend
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"verify",
+		"-file",
+		"mem://test/backup-2.bak",
+		"-key",
+		MySecretKey,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"verify": func() (cli.Command, error) {
+			return &verify.Command{
+				Self: "test-verify",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// prune all but the newest backup
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"prune",
+		"-path",
+		"mem://test",
+		"-match",
+		"backup-*.bak",
+		"-keep-last",
+		"1",
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"prune": func() (cli.Command, error) {
+			return &prune.Command{
+				Self: "test-prune",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// only the newest backup and signature should remain
+	assert.Contains(suite.T(), store.objects, "mem://test/backup-2.bak", "newest backup removed")
+	assert.Contains(suite.T(), store.objects, "mem://test/backup-2.bak.sig", "newest signature removed")
+	assert.Len(suite.T(), store.objects, 2, "old backup not removed")
+}
+
+func (suite *BackinatorTestSuite) Test28GCS() {
+	var c *cli.CLI      // cli object
+	var status int      // exit status
+	var err error       // error holder
+	var server *fakeGCS // fake gcs server
+
+	// point the gcs backend at a fake server
+	server = newFakeGCS()
+	defer server.server.Close()
+	os.Setenv("STORAGE_EMULATOR_HOST", server.server.Listener.Addr().String())
+	defer os.Unsetenv("STORAGE_EMULATOR_HOST")
+
+	// write two backups to the fake bucket
+	for _, name := range []string{"gs://bucket/test/backup-1.bak", "gs://bucket/test/backup-2.bak"} {
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = []string{
+			"backup",
+			"-file",
+			name,
+			"-key",
+			MySecretKey,
+			"-bundle",
+			"kv",
+			"-addr",
+			suite.TestSource.HTTPAddr,
+			"-dc",
+			suite.TestSource.Config.Datacenter,
+			"-token",
+			MyAwesomeToken,
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"backup": func() (cli.Command, error) {
+				return &backup.Command{
+					Self:    "test-backup",
+					Version: appVersion,
+					Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		status, err = c.Run()
+		assert.NoError(suite.T(), err, "operation returned error")
+		assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	}
+	assert.Len(suite.T(), server.objects, 4, "backups or signatures not written")
+
+	// restore the newest backup from the fake bucket
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		"gs://bucket/test/backup-2.bak",
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"kv",
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// prune all but the newest backup
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"prune",
+		"-path",
+		"gs://bucket/test",
+		"-match",
+		"backup-*.bak",
+		"-keep-last",
+		"1",
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"prune": func() (cli.Command, error) {
+			return &prune.Command{
+				Self: "test-prune",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// only the newest backup and signature should remain
+	assert.Contains(suite.T(), server.objects, "bucket/test/backup-2.bak", "newest backup removed")
+	assert.Contains(suite.T(), server.objects, "bucket/test/backup-2.bak.sig", "newest signature removed")
+	assert.Len(suite.T(), server.objects, 2, "old backup not removed")
+}
+
+func (suite *BackinatorTestSuite) Test29Azure() {
+	var c *cli.CLI        // cli object
+	var status int        // exit status
+	var err error         // error holder
+	var server *fakeAzure // fake azure server
+
+	// point the azure backend at a fake server using the azurite account
+	server = newFakeAzure()
+	defer server.server.Close()
+	location := "azblob://devstoreaccount1:" + azuriteKey + "@container/test"
+	options := "?endpoint=" + server.server.URL + "/devstoreaccount1"
+
+	// write two backups to the fake container
+	for _, name := range []string{"backup-1.bak", "backup-2.bak"} {
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = []string{
+			"backup",
+			"-file",
+			location + "/" + name + options,
+			"-key",
+			MySecretKey,
+			"-bundle",
+			"kv",
+			"-addr",
+			suite.TestSource.HTTPAddr,
+			"-dc",
+			suite.TestSource.Config.Datacenter,
+			"-token",
+			MyAwesomeToken,
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"backup": func() (cli.Command, error) {
+				return &backup.Command{
+					Self:    "test-backup",
+					Version: appVersion,
+					Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		status, err = c.Run()
+		assert.NoError(suite.T(), err, "operation returned error")
+		assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	}
+	assert.Len(suite.T(), server.objects, 4, "backups or signatures not written")
+
+	// restore the newest backup from the fake container
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		location + "/backup-2.bak" + options,
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"kv",
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// prune all but the newest backup
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"prune",
+		"-path",
+		location + options,
+		"-match",
+		"backup-*.bak",
+		"-keep-last",
+		"1",
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"prune": func() (cli.Command, error) {
+			return &prune.Command{
+				Self: "test-prune",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// only the newest backup and signature should remain
+	assert.Contains(suite.T(), server.objects, "container/test/backup-2.bak", "newest backup removed")
+	assert.Contains(suite.T(), server.objects, "container/test/backup-2.bak.sig", "newest signature removed")
+	assert.Len(suite.T(), server.objects, 2, "old backup not removed")
+}
+
+func (suite *BackinatorTestSuite) Test30SFTP() {
+	var c *cli.CLI       // cli object
+	var status int       // exit status
+	var err error        // error holder
+	var server *fakeSFTP // fake sftp server
+	var dir string       // server directory
+
+	// start a fake server trusted by a temporary known_hosts file
+	if dir, err = ioutil.TempDir(os.TempDir(), appName+".sftp"); err != nil {
+		suite.T().Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if server, err = newFakeSFTP(dir, nil); err != nil {
+		suite.T().Fatal(err)
+	}
+	defer server.listener.Close()
+	location := "sftp://tester@" + server.listener.Addr().String() + filepath.ToSlash(dir) + "/backups"
+	options := "?identity=" + server.identity + "&known_hosts=" + server.knownHosts
+
+	// write two backups to the fake server
+	for _, name := range []string{"backup-1.bak", "backup-2.bak"} {
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = []string{
+			"backup",
+			"-file",
+			location + "/" + name + options,
+			"-key",
+			MySecretKey,
+			"-bundle",
+			"kv",
+			"-addr",
+			suite.TestSource.HTTPAddr,
+			"-dc",
+			suite.TestSource.Config.Datacenter,
+			"-token",
+			MyAwesomeToken,
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"backup": func() (cli.Command, error) {
+				return &backup.Command{
+					Self:    "test-backup",
+					Version: appVersion,
+					Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		status, err = c.Run()
+		assert.NoError(suite.T(), err, "operation returned error")
+		assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	}
+
+	// only the renamed backups and signatures should exist
+	names, _ := filepath.Glob(filepath.Join(dir, "backups", "*"))
+	assert.Len(suite.T(), names, 4, "backups or signatures not written")
+	temps, _ := filepath.Glob(filepath.Join(dir, "backups", ".*"))
+	assert.Empty(suite.T(), temps, "temporary files left behind")
+	if info, err := os.Stat(filepath.Join(dir, "backups", "backup-2.bak")); assert.NoError(suite.T(), err, "failed to stat file") {
+		assert.Equal(suite.T(), os.FileMode(0600), info.Mode().Perm(), "backup readable by others")
+	}
+
+	// restore the newest backup from the fake server
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		location + "/backup-2.bak" + options,
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"kv",
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// prune all but the newest backup
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"prune",
+		"-path",
+		location + options,
+		"-match",
+		"backup-*.bak",
+		"-keep-last",
+		"1",
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"prune": func() (cli.Command, error) {
+			return &prune.Command{
+				Self: "test-prune",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	names, _ = filepath.Glob(filepath.Join(dir, "backups", "*"))
+	assert.Len(suite.T(), names, 2, "old backup not removed")
+
+	// hosts missing from known_hosts are rejected
+	assert.NoError(suite.T(), ioutil.WriteFile(server.knownHosts, nil, 0600), "failed to write file")
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"prune",
+		"-path",
+		location + options,
+		"-keep-last",
+		"1",
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"prune": func() (cli.Command, error) {
+			return &prune.Command{
+				Self: "test-prune",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	status, err = c.Run()
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.NotEqual(suite.T(), status, 0, "unknown host accepted")
+}
+
+func (suite *BackinatorTestSuite) Test31Stream() {
+	var c *cli.CLI              // cli object
+	var status int              // exit status
+	var err error               // error holder
+	var stream *os.File         // captured stream
+	var stdin, stdout *os.File  // original stdin and stdout
+	var out []byte              // stream contents
+	var sigFile = mktemp("sig") // separate signature
+
+	// capture stdout in a temporary file
+	if stream, err = ioutil.TempFile(os.TempDir(), appName+".stream"); err != nil {
+		suite.T().Fatal(err)
+	}
+	defer os.Remove(stream.Name())
+	defer stream.Close()
+	defer os.Remove(sigFile)
+	stdin, stdout = os.Stdin, os.Stdout
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+
+	// stream a bundle with an embedded signature to stdout
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"backup",
+		"-file",
+		"-",
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"kv",
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"backup": func() (cli.Command, error) {
+			return &backup.Command{
+				Self:    "test-backup",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	os.Stdout = stream
+	status, err = c.Run()
+	os.Stdout = stdout
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	out, _ = ioutil.ReadFile(stream.Name())
+	assert.NotEmpty(suite.T(), out, "nothing written to stdout")
+	assert.NotContains(suite.T(), string(out), "Keep your backup", "message mixed into stream")
+	_, err = os.Stat("-.sig")
+	assert.True(suite.T(), os.IsNotExist(err), "signature written next to stdout")
+
+	// restore the bundle from stdin
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		"-",
+		"-key",
+		MySecretKey,
+		"-bundle",
+		"kv",
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	stream.Seek(0, 0)
+	os.Stdin = stream
+	status, err = c.Run()
+	os.Stdin = stdin
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// stream keys with a separate signature
+	stream.Truncate(0)
+	stream.Seek(0, 0)
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"backup",
+		"-file",
+		"-",
+		"-sig",
+		sigFile,
+		"-key",
+		MySecretKey,
+		"-addr",
+		suite.TestSource.HTTPAddr,
+		"-dc",
+		suite.TestSource.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"backup": func() (cli.Command, error) {
+			return &backup.Command{
+				Self:    "test-backup",
+				Version: appVersion,
+				Log:     stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	os.Stdout = stream
+	status, err = c.Run()
+	os.Stdout = stdout
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+	if info, err := os.Stat(sigFile); assert.NoError(suite.T(), err, "failed to stat file") {
+		assert.NotZero(suite.T(), info.Size(), "signature not written")
+	}
+
+	// verify from stdin only succeeds with the separate signature
+	for _, withSig := range []bool{true, false} {
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = []string{
+			"verify",
+			"-file",
+			"-",
+			"-key",
+			MySecretKey,
+		}
+		if withSig {
+			c.Args = append(c.Args, "-sig", sigFile)
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"verify": func() (cli.Command, error) {
+				return &verify.Command{
+					Self: "test-verify",
+					Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		stream.Seek(0, 0)
+		os.Stdin = stream
+		status, err = c.Run()
+		os.Stdin = stdin
+		assert.NoError(suite.T(), err, "operation returned error")
+		if withSig {
+			assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+		} else {
+			assert.NotEqual(suite.T(), status, 0, "stream without signature verified")
+		}
+	}
+}
+
+func (suite *BackinatorTestSuite) Test32RestoreDryRunMirror() {
+	var c *cli.CLI      // cli object
+	var status int      // exit status
+	var err error       // error holder
+	var r, w *os.File   // stdout pipe
+	var stdout *os.File // original stdout
+	var out []byte      // captured output
+
+	// change a key present in the backup and add one that is not
+	suite.TestTarget.SetKVString(suite.T(), "key1", "changed32")
+	suite.TestTarget.SetKVString(suite.T(), "dryrun/key8", "value8")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestKeyFile,
+		"-key",
+		MySecretKey,
+		"-mirror",
+		"-conflict",
+		"skip-existing",
+		"-dry-run",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// capture output
+	r, w, err = os.Pipe()
+	assert.NoError(suite.T(), err, "failed to create pipe")
+	stdout, os.Stdout = os.Stdout, w
+
+	// run command
+	status, err = c.Run()
+
+	// restore output
+	os.Stdout = stdout
+	w.Close()
+	out, _ = ioutil.ReadAll(r)
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// a mirror keeps skipped keys and only deletes keys absent from the backup
+	assert.Contains(suite.T(), string(out), "[kv] skip key1\n", "conflict not skipped")
+	assert.Contains(suite.T(), string(out), "[kv] delete dryrun/key8\n", "absent key not deleted")
+	assert.NotContains(suite.T(), string(out), "[kv] delete key1\n", "skipped key deleted")
+	assert.Equal(suite.T(), "changed32", suite.TestTarget.GetKVString(suite.T(), "key1"),
+		"dry run modified target")
+}
+
+func (suite *BackinatorTestSuite) Test33RestoreNewerWins() {
+	var c *cli.CLI         // cli object
+	var client *api.Client // target client
+	var config *api.Config // target client config
+	var status int         // exit status
+	var err error          // error holder
+
+	// build target client
+	config = api.DefaultConfig()
+	config.Address = suite.TestTarget.HTTPAddr
+	config.Datacenter = suite.TestTarget.Config.Datacenter
+	config.Token = MyAwesomeToken
+	if client, err = api.NewClient(config); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	// modify a key after the backup was taken and remove another
+	suite.TestTarget.SetKVString(suite.T(), "key1", "changed33")
+	_, err = client.KV().Delete("folder1/key2", nil)
+	assert.NoError(suite.T(), err, "api kv operation returned error")
+
+	// init and populate cli object
+	c = cli.NewCLI(appName, appVersion)
+	c.Args = []string{
+		"restore",
+		"-file",
+		suite.TestKeyFile,
+		"-key",
+		MySecretKey,
+		"-conflict",
+		"newer-wins",
+		"-no-snapshot",
+		"-addr",
+		suite.TestTarget.HTTPAddr,
+		"-dc",
+		suite.TestTarget.Config.Datacenter,
+		"-token",
+		MyAwesomeToken,
+	}
+	c.Commands = map[string]cli.CommandFactory{
+		"restore": func() (cli.Command, error) {
+			return &restore.Command{
+				Self: "test-restore",
+				Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+			}, nil
+		},
+	}
+	// run command
+	status, err = c.Run()
+
+	// check results
+	assert.NoError(suite.T(), err, "operation returned error")
+	assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+	// the newer live key should be kept and the missing key restored
+	assert.Equal(suite.T(), "changed33", suite.TestTarget.GetKVString(suite.T(), "key1"),
+		"newer key overwritten")
+	assert.Equal(suite.T(), "value2", suite.TestTarget.GetKVString(suite.T(), "folder1/key2"),
+		"missing key not restored")
+}
+
+func (suite *BackinatorTestSuite) Test34RestoreCAS() {
+	var c *cli.CLI         // cli object
+	var client *api.Client // target client
+	var config *api.Config // target client config
+	var status int         // exit status
+	var err error          // error holder
+
+	// build target client
+	config = api.DefaultConfig()
+	config.Address = suite.TestTarget.HTTPAddr
+	config.Datacenter = suite.TestTarget.Config.Datacenter
+	config.Token = MyAwesomeToken
+	if client, err = api.NewClient(config); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	// restore with and without transactions
+	for _, atomic := range []bool{false, true} {
+		// modify a key and remove another
+		suite.TestTarget.SetKVString(suite.T(), "key1", "changed34")
+		_, err = client.KV().Delete("folder1/key2", nil)
+		assert.NoError(suite.T(), err, "api kv operation returned error")
+
+		// init and populate cli object
+		c = cli.NewCLI(appName, appVersion)
+		c.Args = []string{
+			"restore",
+			"-file",
+			suite.TestKeyFile,
+			"-key",
+			MySecretKey,
+			"-conflict",
+			"cas",
+			"-no-snapshot",
+			"-addr",
+			suite.TestTarget.HTTPAddr,
+			"-dc",
+			suite.TestTarget.Config.Datacenter,
+			"-token",
+			MyAwesomeToken,
+		}
+		if atomic {
+			c.Args = append(c.Args, "-atomic")
+		}
+		c.Commands = map[string]cli.CommandFactory{
+			"restore": func() (cli.Command, error) {
+				return &restore.Command{
+					Self: "test-restore",
+					Log:  stdLog.New(os.Stderr, "", stdLog.LstdFlags),
+				}, nil
+			},
+		}
+		// run command
+		status, err = c.Run()
+
+		// check results
+		assert.NoError(suite.T(), err, "operation returned error")
+		assert.Equal(suite.T(), status, 0, "operation exited non-zero")
+
+		// unchanged keys should be overwritten and missing keys created
+		assert.Equal(suite.T(), "value1", suite.TestTarget.GetKVString(suite.T(), "key1"),
+			"existing key not overwritten")
+		assert.Equal(suite.T(), "value2", suite.TestTarget.GetKVString(suite.T(), "folder1/key2"),
+			"missing key not created")
+	}
+}
+
+func (suite *BackinatorTestSuite) Test35StoreURIs() {
+	// '@' and '?' in object paths are not taken for credentials or options
+	for name, expected := range map[string]string{
+		"s3://bucket/backups/a@b.bak":                         "s3://bucket/backups/a@b.bak.sig",
+		"s3://bucket/backups/a@b.bak?region=us-west-2":        "s3://bucket/backups/a@b.bak.sig?region=us-west-2",
+		"s3://bucket/backups/backup-?.bak":                    "s3://bucket/backups/backup-?.bak.sig",
+		"s3://bucket/backups/backup-?.bak?region=us-west-2":   "s3://bucket/backups/backup-?.bak.sig?region=us-west-2",
+		"s3://key:se/cret@bucket/backup.bak?region=us-west-2": "s3://key:se/cret@bucket/backup.bak.sig?region=us-west-2",
+		"sftp://backup-host:22/srv/a@b.bak":                   "sftp://backup-host:22/srv/a@b.bak.sig",
+		"sftp://backup@backup-host/srv/backup.bak":            "sftp://backup@backup-host/srv/backup.bak.sig",
+	} {
+		assert.Equal(suite.T(), expected, common.AppendSuffix(name, ".sig"), "suffix misplaced")
+	}
+
+	// glob characters in patterns are kept in the path
+	assert.Equal(suite.T(), "s3://bucket/backups/backup-?.bak?region=us-west-2",
+		common.JoinPattern("s3://bucket/backups?region=us-west-2", "backup-?.bak"), "pattern misplaced")
+	assert.Equal(suite.T(), "s3://bucket/a@b/backup-*.bak",
+		common.JoinPattern("s3://bucket/a@b", "backup-*.bak"), "pattern misplaced")
+}
+
+func (suite *BackinatorTestSuite) Test36SFTPRenameFallback() {
+	var server *fakeSFTP       // fake sftp server
+	var handlers sftp.Handlers // in memory files
+	var cmds *failRename       // failing rename commands
+	var keys *common.Keys      // encryption keys
+	var data []byte            // read data
+	var dir string             // server directory
+	var err error              // error holder
+
+	// serve files from memory without the posix rename extension
+	assert.NoError(suite.T(), sftp.SetSFTPExtensions("statvfs@openssh.com"), "failed to set extensions")
+	defer sftp.SetSFTPExtensions("hardlink@openssh.com", "posix-rename@openssh.com", "statvfs@openssh.com")
+	handlers = sftp.InMemHandler()
+	cmds = &failRename{FileCmder: handlers.FileCmd}
+	handlers.FileCmd = cmds
+
+	// start a fake server trusted by a temporary known_hosts file
+	if dir, err = ioutil.TempDir(os.TempDir(), appName+".sftp"); err != nil {
+		suite.T().Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if server, err = newFakeSFTP(dir, &handlers); err != nil {
+		suite.T().Fatal(err)
+	}
+	defer server.listener.Close()
+	location := "sftp://tester@" + server.listener.Addr().String() + "/backups/backup.bak" +
+		"?identity=" + server.identity + "&known_hosts=" + server.knownHosts
+	keys = common.NewKeys(MySecretKey)
+
+	// an existing file is replaced without the extension
+	for _, value := range []string{"first", "second"} {
+		assert.NoError(suite.T(), common.WriteData(location, "", keys, []byte(value)), "failed to write data")
+	}
+	data, err = common.ReadData(location, "", keys)
+	if assert.NoError(suite.T(), err, "failed to read data") {
+		assert.Equal(suite.T(), "second", string(data), "file not replaced")
+	}
+
+	// the previous file is kept when the new file can not be renamed into place
+	cmds.fail = true
+	assert.Error(suite.T(), common.WriteData(location, "", keys, []byte("third")), "failed rename not reported")
+	cmds.fail = false
+	data, err = common.ReadData(location, "", keys)
+	if assert.NoError(suite.T(), err, "previous file lost") {
+		assert.Equal(suite.T(), "second", string(data), "previous file replaced")
+	}
+}
+
 func TestBackinatorTestSuite(t *testing.T) {
 	suite.Run(t, new(BackinatorTestSuite))
 }